@@ -30,7 +30,9 @@ func InitOAuth() {
 		ClientID:     os.Getenv("TWITTER_CLIENT_ID"),
 		ClientSecret: os.Getenv("TWITTER_CLIENT_SECRET"),
 		RedirectURL:  os.Getenv("BACKEND_URL") + "/api/auth/twitter/callback",
-		Scopes:       []string{"tweet.read", "users.read", "bookmark.read"},
+		// offline.access issues a refresh token, so bookmark sync keeps
+		// working after the two-hour access token expires.
+		Scopes: []string{"tweet.read", "users.read", "bookmark.read", "offline.access"},
 		Endpoint: oauth2.Endpoint{
 			AuthURL:  "https://twitter.com/i/oauth2/authorize",
 			TokenURL: "https://api.twitter.com/2/oauth2/token",
@@ -68,6 +70,11 @@ func ExchangeCodeForToken(ctx context.Context, code string) (*oauth2.Token, erro
 	return token, err
 }
 
+// TokenSource returns a token source that refreshes token when it expires.
+func TokenSource(ctx context.Context, token *oauth2.Token) oauth2.TokenSource {
+	return oauthConfig.TokenSource(ctx, token)
+}
+
 func GetTwitterUserInfo(ctx context.Context, token *oauth2.Token) (*TwitterUser, error) {
 	client := oauthConfig.Client(ctx, token)
 	resp, err := client.Get("https://api.twitter.com/2/users/me?user.fields=profile_image_url")
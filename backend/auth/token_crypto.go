@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// sealedTokenPrefix marks tokens encrypted by SealToken. Tokens stored before
+// encryption was added have no prefix and are read as they are.
+const sealedTokenPrefix = "enc:v1:"
+
+var ErrTokenKeyNotSet = errors.New("X_TOKEN_ENCRYPTION_KEY not set")
+
+// CheckTokenKey reports whether X_TOKEN_ENCRYPTION_KEY holds a usable key,
+// returning ErrTokenKeyNotSet when it is unset.
+func CheckTokenKey() error {
+	_, err := tokenCipher()
+	return err
+}
+
+// SealToken encrypts an OAuth token for storage with AES-256-GCM under
+// X_TOKEN_ENCRYPTION_KEY, a base64-encoded 32-byte key.
+func SealToken(token string) (string, error) {
+	if token == "" {
+		return "", nil
+	}
+	aead, err := tokenCipher()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(token), nil)
+	return sealedTokenPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// OpenToken decrypts a token sealed by SealToken.
+func OpenToken(stored string) (string, error) {
+	if !strings.HasPrefix(stored, sealedTokenPrefix) {
+		return stored, nil
+	}
+	aead, err := tokenCipher()
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, sealedTokenPrefix))
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("sealed token is too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	token, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt token: %w", err)
+	}
+	return string(token), nil
+}
+
+func tokenCipher() (cipher.AEAD, error) {
+	encoded := os.Getenv("X_TOKEN_ENCRYPTION_KEY")
+	if encoded == "" {
+		return nil, ErrTokenKeyNotSet
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("X_TOKEN_ENCRYPTION_KEY must be 32 bytes, base64-encoded")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,148 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"twitter-bookmarks-api/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ExecuteBulkAction applies a bulk action to the selected bookmarks of a user
+// in a single transaction. Explicit IDs go through the same ownership checks
+// as AssignBookmarkToCategory; any failure rolls back the whole action. The
+// action is recorded in the operation log within the same transaction.
+func ExecuteBulkAction(ctx context.Context, userID uuid.UUID, action models.BulkAction) (*models.BulkActionResult, error) {
+	tx, err := DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	ids, err := selectBulkBookmarks(ctx, tx, userID, action)
+	if err != nil {
+		return nil, err
+	}
+
+	if action.CategoryID != nil {
+		if err := checkCategoryOwner(ctx, tx, *action.CategoryID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &models.BulkActionResult{Action: action.Action, Matched: len(ids)}
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	// Every statement returns the ids it changed so that the inverse only
+	// touches bookmarks this action actually affected.
+	var query string
+	args := []interface{}{ids}
+	switch action.Action {
+	case models.BulkActionDelete:
+		query = `UPDATE bookmarks SET deleted_at = NOW() WHERE id = ANY($1) AND deleted_at IS NULL RETURNING id`
+	case models.BulkActionArchive:
+		query = `UPDATE bookmarks SET archived_at = NOW(), updated_at = NOW() WHERE id = ANY($1) AND archived_at IS NULL RETURNING id`
+	case models.BulkActionMarkRead:
+		query = `UPDATE bookmarks SET read_at = NOW(), updated_at = NOW() WHERE id = ANY($1) AND read_at IS NULL RETURNING id`
+	case models.BulkActionAssignCategory:
+		query = `
+			INSERT INTO bookmark_categories (bookmark_id, category_id)
+			SELECT id, $2 FROM unnest($1::uuid[]) AS id
+			ON CONFLICT (bookmark_id, category_id) DO NOTHING
+			RETURNING bookmark_id
+		`
+		args = append(args, *action.CategoryID)
+	case models.BulkActionRemoveCategory:
+		query = `DELETE FROM bookmark_categories WHERE bookmark_id = ANY($1) AND category_id = $2 RETURNING bookmark_id`
+		args = append(args, *action.CategoryID)
+	default:
+		return nil, fmt.Errorf("unknown action %q", action.Action)
+	}
+
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	affected, err := collectIDs(rows)
+	if err != nil {
+		return nil, err
+	}
+	result.Affected = len(affected)
+
+	if len(affected) > 0 {
+		inverse := bulkInverse(action, affected)
+		operationID, err := recordOperation(ctx, tx, userID, models.OperationBulkPrefix+action.Action, inverse)
+		if err != nil {
+			return nil, err
+		}
+		result.OperationID = &operationID
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func bulkInverse(action models.BulkAction, affected []uuid.UUID) models.OperationInverse {
+	var inverse models.OperationInverse
+	switch action.Action {
+	case models.BulkActionDelete:
+		inverse.RestoreBookmarks = affected
+	case models.BulkActionArchive:
+		inverse.UnarchiveBookmarks = affected
+	case models.BulkActionMarkRead:
+		inverse.MarkUnread = affected
+	case models.BulkActionAssignCategory, models.BulkActionRemoveCategory:
+		assignments := make([]models.BookmarkCategory, len(affected))
+		for i, id := range affected {
+			assignments[i] = models.BookmarkCategory{BookmarkID: id, CategoryID: *action.CategoryID}
+		}
+		if action.Action == models.BulkActionAssignCategory {
+			inverse.RemoveAssignments = assignments
+		} else {
+			inverse.AddAssignments = assignments
+		}
+	}
+	return inverse
+}
+
+// selectBulkBookmarks resolves the bookmarks a bulk action applies to,
+// locking them for the rest of the transaction.
+func selectBulkBookmarks(ctx context.Context, q querier, userID uuid.UUID, action models.BulkAction) ([]uuid.UUID, error) {
+	if action.Filter == nil {
+		if err := checkBookmarksOwner(ctx, q, action.IDs, userID); err != nil {
+			return nil, err
+		}
+		rows, err := q.Query(ctx, `SELECT id FROM bookmarks WHERE id = ANY($1) FOR UPDATE`, action.IDs)
+		if err != nil {
+			return nil, err
+		}
+		return collectIDs(rows)
+	}
+
+	where, args := appendSearchQuery("WHERE b.user_id = $1 AND b.deleted_at IS NULL", []interface{}{userID}, action.Query)
+	where, args = appendBookmarkFilter(where, args, *action.Filter)
+	rows, err := q.Query(ctx, "SELECT b.id FROM bookmarks b "+where+" FOR UPDATE", args...)
+	if err != nil {
+		return nil, err
+	}
+	return collectIDs(rows)
+}
+
+func collectIDs(rows pgx.Rows) ([]uuid.UUID, error) {
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,146 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"twitter-bookmarks-api/models"
+
+	"github.com/google/uuid"
+)
+
+// GetExpandedLinksByUserID returns the expanded links found by the status
+// checker for each of the user's bookmarks.
+func GetExpandedLinksByUserID(ctx context.Context, userID uuid.UUID) (map[uuid.UUID][]string, error) {
+	query := `
+		SELECT l.bookmark_id, l.expanded_url
+		FROM bookmark_links l
+		JOIN bookmarks b ON b.id = l.bookmark_id
+		WHERE b.user_id = $1 AND b.deleted_at IS NULL AND COALESCE(l.expanded_url, '') <> ''
+	`
+	rows, err := DB.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := make(map[uuid.UUID][]string)
+	for rows.Next() {
+		var id uuid.UUID
+		var link string
+		if err := rows.Scan(&id, &link); err != nil {
+			return nil, err
+		}
+		links[id] = append(links[id], link)
+	}
+	return links, rows.Err()
+}
+
+// MergeBookmarks keeps keepID and folds the bookmarks in mergeIDs into it:
+// their categories are assigned to the kept bookmark, their notes are
+// appended to its notes, and they are moved to the trash. Everything happens
+// in one transaction, which also records the merge in the operation log.
+func MergeBookmarks(ctx context.Context, userID, keepID uuid.UUID, mergeIDs []uuid.UUID) (*models.Bookmark, *uuid.UUID, error) {
+	tx, err := DB.Begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	allIDs := append([]uuid.UUID{keepID}, mergeIDs...)
+	if err := checkBookmarksOwner(ctx, tx, allIDs, userID); err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT `+bookmarkColumns+`
+		FROM bookmarks b
+		WHERE b.id = ANY($1)
+		ORDER BY b.bookmarked_at ASC
+		FOR UPDATE
+	`, allIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	bookmarks, err := collectBookmarks(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var keep models.Bookmark
+	var notes []string
+	for _, b := range bookmarks {
+		if b.ID == keepID {
+			keep = b
+		}
+	}
+	if keep.Notes != "" {
+		notes = append(notes, keep.Notes)
+	}
+	for _, b := range bookmarks {
+		if b.ID == keepID || b.Notes == "" || strings.Contains(keep.Notes, b.Notes) {
+			continue
+		}
+		notes = append(notes, b.Notes)
+	}
+
+	var inverse models.OperationInverse
+
+	rows, err = tx.Query(ctx, `
+		INSERT INTO bookmark_categories (bookmark_id, category_id)
+		SELECT DISTINCT $1::uuid, bc.category_id
+		FROM bookmark_categories bc
+		JOIN categories c ON c.id = bc.category_id AND c.deleted_at IS NULL
+		WHERE bc.bookmark_id = ANY($2)
+		ON CONFLICT (bookmark_id, category_id) DO NOTHING
+		RETURNING category_id
+	`, keepID, mergeIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	added, err := collectIDs(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, categoryID := range added {
+		inverse.RemoveAssignments = append(inverse.RemoveAssignments,
+			models.BookmarkCategory{BookmarkID: keepID, CategoryID: categoryID})
+	}
+
+	if merged := strings.Join(notes, "\n\n"); merged != keep.Notes {
+		_, err := tx.Exec(ctx, `UPDATE bookmarks SET notes = $1, updated_at = NOW() WHERE id = $2`, merged, keepID)
+		if err != nil {
+			return nil, nil, err
+		}
+		inverse.BookmarkEdits = []models.BookmarkSnapshot{{
+			ID:           keep.ID,
+			Title:        keep.Title,
+			TweetText:    keep.TweetText,
+			Lang:         keep.Lang,
+			BookmarkedAt: keep.BookmarkedAt,
+			Notes:        keep.Notes,
+			Metadata:     keep.Metadata,
+		}}
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE bookmarks SET deleted_at = NOW() WHERE id = ANY($1)`, mergeIDs); err != nil {
+		return nil, nil, err
+	}
+	inverse.RestoreBookmarks = mergeIDs
+
+	operationID, err := recordOperation(ctx, tx, userID, models.OperationMergeDuplicates, inverse)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	bookmark, err := GetBookmarkByID(ctx, keepID, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to reload bookmark: %w", err)
+	}
+	return bookmark, &operationID, nil
+}
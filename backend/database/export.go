@@ -0,0 +1,135 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"twitter-bookmarks-api/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// exportCategoryColumns follow bookmarkColumns in export queries. They are
+// NULL for bookmarks without a category.
+const exportCategoryColumns = `c.id, c.user_id, COALESCE(c.name, ''), COALESCE(c.color, ''),
+		COALESCE(c.icon, ''), c.created_at`
+
+// scanExportRow scans a row selected with bookmarkColumns and
+// exportCategoryColumns, returning a nil category for NULL ones.
+func scanExportRow(rows pgx.Rows) (*models.Bookmark, *models.Category, error) {
+	var b models.Bookmark
+	var categoryID, categoryUserID *uuid.UUID
+	var name, color, icon string
+	var createdAt *time.Time
+	if err := scanBookmark(rows, &b, &categoryID, &categoryUserID, &name, &color, &icon, &createdAt); err != nil {
+		return nil, nil, err
+	}
+	if categoryID == nil {
+		return &b, nil, nil
+	}
+
+	category := &models.Category{ID: *categoryID, Name: name, Color: color, Icon: icon}
+	if categoryUserID != nil {
+		category.UserID = *categoryUserID
+	}
+	if createdAt != nil {
+		category.CreatedAt = *createdAt
+	}
+	return &b, category, nil
+}
+
+// StreamBookmarks calls fn with each of the user's bookmarks matching filter,
+// newest first, with their categories attached. Rows are read from the
+// connection as fn consumes them, so memory use does not grow with the size
+// of the library. An error returned by fn stops the stream and is returned.
+func StreamBookmarks(ctx context.Context, userID uuid.UUID, filter models.BookmarkFilter, fn func(*models.Bookmark) error) error {
+	where, args := appendBookmarkFilter("WHERE b.user_id = $1 AND b.deleted_at IS NULL", []interface{}{userID}, filter)
+
+	// One row per bookmark and category, so that a bookmark's categories
+	// arrive together and it can be handed on once the next one starts.
+	query := `
+		SELECT ` + bookmarkColumns + `, ` + exportCategoryColumns + `
+		FROM bookmarks b
+		LEFT JOIN (bookmark_categories bc JOIN categories c ON c.id = bc.category_id AND c.deleted_at IS NULL)
+		     ON bc.bookmark_id = b.id
+		` + where + `
+		ORDER BY b.bookmarked_at DESC, b.id, c.name
+	`
+	rows, err := DB.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var current *models.Bookmark
+	for rows.Next() {
+		b, category, err := scanExportRow(rows)
+		if err != nil {
+			return err
+		}
+
+		if current == nil || current.ID != b.ID {
+			if current != nil {
+				if err := fn(current); err != nil {
+					return err
+				}
+			}
+			current = b
+		}
+		if category != nil {
+			current.Categories = append(current.Categories, *category)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if current != nil {
+		return fn(current)
+	}
+	return nil
+}
+
+// StreamBookmarksByCategory is StreamBookmarks grouped by category for
+// formats that nest bookmarks in folders. fn gets each bookmark once per
+// category it is in, with Categories holding just that category. Categories
+// come in name order, newest bookmark first within each, followed by the
+// bookmarks without a category. When filter names a category, only that
+// category's bookmarks are passed, once each.
+func StreamBookmarksByCategory(ctx context.Context, userID uuid.UUID, filter models.BookmarkFilter, fn func(*models.Bookmark) error) error {
+	args := []interface{}{userID}
+	categoryJoin := ""
+	if filter.CategoryID != nil {
+		args = append(args, *filter.CategoryID)
+		categoryJoin = fmt.Sprintf(" AND c.id = $%d", len(args))
+	}
+	where, args := appendBookmarkFilter("WHERE b.user_id = $1 AND b.deleted_at IS NULL", args, filter)
+
+	query := `
+		SELECT ` + bookmarkColumns + `, ` + exportCategoryColumns + `
+		FROM bookmarks b
+		LEFT JOIN (bookmark_categories bc JOIN categories c ON c.id = bc.category_id AND c.deleted_at IS NULL` + categoryJoin + `)
+		     ON bc.bookmark_id = b.id
+		` + where + `
+		ORDER BY c.name NULLS LAST, c.id, b.bookmarked_at DESC
+	`
+	rows, err := DB.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		b, category, err := scanExportRow(rows)
+		if err != nil {
+			return err
+		}
+		if category != nil {
+			b.Categories = []models.Category{*category}
+		}
+		if err := fn(b); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
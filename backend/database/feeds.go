@@ -0,0 +1,75 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"twitter-bookmarks-api/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrFeedNotFound = errors.New("feed not found")
+
+// CreateFeed stores a feed under the hash of its token.
+func CreateFeed(ctx context.Context, feed *models.Feed, tokenHash string) error {
+	query := `
+		INSERT INTO feeds (user_id, category_id, token_hash)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`
+	return DB.QueryRow(ctx, query, feed.UserID, feed.CategoryID, tokenHash).Scan(&feed.ID, &feed.CreatedAt)
+}
+
+func GetFeeds(ctx context.Context, userID uuid.UUID) ([]models.Feed, error) {
+	rows, err := DB.Query(ctx, `
+		SELECT id, user_id, category_id, created_at, last_used_at
+		FROM feeds
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feeds := []models.Feed{}
+	for rows.Next() {
+		var f models.Feed
+		if err := rows.Scan(&f.ID, &f.UserID, &f.CategoryID, &f.CreatedAt, &f.LastUsedAt); err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, f)
+	}
+	return feeds, rows.Err()
+}
+
+// UseFeed returns the feed with the given token hash and records that it
+// was fetched.
+func UseFeed(ctx context.Context, tokenHash string) (*models.Feed, error) {
+	f := &models.Feed{}
+	err := DB.QueryRow(ctx, `
+		UPDATE feeds SET last_used_at = NOW()
+		WHERE token_hash = $1
+		RETURNING id, user_id, category_id, created_at, last_used_at
+	`, tokenHash).Scan(&f.ID, &f.UserID, &f.CategoryID, &f.CreatedAt, &f.LastUsedAt)
+	if err == pgx.ErrNoRows {
+		return nil, ErrFeedNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// DeleteFeed revokes a feed: its token stops working right away.
+func DeleteFeed(ctx context.Context, feedID, userID uuid.UUID) error {
+	result, err := DB.Exec(ctx, `DELETE FROM feeds WHERE id = $1 AND user_id = $2`, feedID, userID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrFeedNotFound
+	}
+	return nil
+}
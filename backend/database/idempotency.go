@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is still in progress")
+	ErrIdempotencyKeyReused     = errors.New("idempotency key was used for a different request")
+)
+
+// StoredResponse is a response saved under an idempotency key. BodyHash is
+// set for streamed requests, whose body is not part of the request hash.
+type StoredResponse struct {
+	StatusCode int
+	Headers    map[string]string
+	Body       []byte
+	BodyHash   string
+}
+
+// ReserveIdempotencyKey claims key for a request whose contents hash to
+// requestHash. It returns nil when the key is new and the request should run,
+// or the stored response when the same request already completed. Keys
+// older than expiredBefore are forgotten, as are reservations older than
+// abandonedBefore that never got a response, e.g. because the server
+// restarted mid-request.
+func ReserveIdempotencyKey(ctx context.Context, userID uuid.UUID, key, requestHash string, expiredBefore, abandonedBefore time.Time) (*StoredResponse, error) {
+	_, err := DB.Exec(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE user_id = $1 AND (created_at < $2 OR (status_code IS NULL AND created_at < $3))
+	`, userID, expiredBefore, abandonedBefore)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := DB.Exec(ctx, `
+		INSERT INTO idempotency_keys (user_id, key, request_hash)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, key) DO NOTHING
+	`, userID, key, requestHash)
+	if err != nil {
+		return nil, err
+	}
+	if result.RowsAffected() == 1 {
+		return nil, nil
+	}
+
+	var storedHash string
+	var statusCode *int
+	stored := &StoredResponse{}
+	err = DB.QueryRow(ctx, `
+		SELECT request_hash, status_code, COALESCE(headers, '{}'), COALESCE(response, ''), COALESCE(body_hash, '')
+		FROM idempotency_keys WHERE user_id = $1 AND key = $2
+	`, userID, key).Scan(&storedHash, &statusCode, &stored.Headers, &stored.Body, &stored.BodyHash)
+	if err == pgx.ErrNoRows {
+		// Released by a failed request in the meantime; let the caller retry.
+		return nil, ErrIdempotencyKeyInProgress
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case storedHash != requestHash:
+		return nil, ErrIdempotencyKeyReused
+	case statusCode == nil:
+		return nil, ErrIdempotencyKeyInProgress
+	}
+	stored.StatusCode = *statusCode
+	return stored, nil
+}
+
+// SaveIdempotentResponse stores the response of a request that reserved key.
+func SaveIdempotentResponse(ctx context.Context, userID uuid.UUID, key string, response StoredResponse) error {
+	_, err := DB.Exec(ctx, `
+		UPDATE idempotency_keys SET status_code = $3, headers = $4, response = $5, body_hash = NULLIF($6, '')
+		WHERE user_id = $1 AND key = $2
+	`, userID, key, response.StatusCode, response.Headers, response.Body, response.BodyHash)
+	return err
+}
+
+// ReleaseIdempotencyKey forgets a reserved key, so that a request that
+// failed on the server can be retried with it.
+func ReleaseIdempotencyKey(ctx context.Context, userID uuid.UUID, key string) error {
+	_, err := DB.Exec(ctx, `DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2`, userID, key)
+	return err
+}
@@ -0,0 +1,473 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"twitter-bookmarks-api/langdetect"
+	"twitter-bookmarks-api/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// importLockNamespace is the first key of the per-user advisory lock held by
+// an ImportLock, keeping it apart from other advisory locks.
+const importLockNamespace int32 = 1001
+
+var ErrImportInProgress = errors.New("another import is in progress for this user")
+
+// ImportLock serializes a user's imports: it holds a session-level advisory
+// lock on a connection of its own until Release, and the batches of the
+// import run on that connection.
+type ImportLock struct {
+	conn   *pgxpool.Conn
+	userID uuid.UUID
+}
+
+// LockImports takes the user's import lock. With wait it blocks until the
+// lock is free or ctx is done; without, it returns ErrImportInProgress while
+// another import holds the lock.
+func LockImports(ctx context.Context, userID uuid.UUID, wait bool) (*ImportLock, error) {
+	conn, err := DB.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if wait {
+		_, err = conn.Exec(ctx, `SELECT pg_advisory_lock($1, hashtext($2::text))`, importLockNamespace, userID.String())
+	} else {
+		var locked bool
+		err = conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1, hashtext($2::text))`,
+			importLockNamespace, userID.String()).Scan(&locked)
+		if err == nil && !locked {
+			err = ErrImportInProgress
+		}
+	}
+	if err != nil {
+		conn.Release()
+		return nil, err
+	}
+	return &ImportLock{conn: conn, userID: userID}, nil
+}
+
+// Release gives the lock and its connection back. A connection that fails
+// to unlock is closed, which releases the lock too.
+func (l *ImportLock) Release(ctx context.Context) {
+	if l.conn == nil {
+		return
+	}
+	_, err := l.conn.Exec(ctx, `SELECT pg_advisory_unlock($1, hashtext($2::text))`, importLockNamespace, l.userID.String())
+	if err != nil {
+		fmt.Printf("failed to release import lock, closing its connection: %v\n", err)
+		l.conn.Conn().Close(ctx)
+	}
+	l.conn.Release()
+	l.conn = nil
+}
+
+var importStagingColumns = []string{
+	"idx", "tweet_id", "tweet_text", "author_username", "author_display_name",
+	"tweet_url", "media_urls", "bookmarked_at", "lang", "search_config",
+}
+
+// importMergeQuery applies a staged import in one statement. For tweets the
+// user already has, mode ($2) decides what happens:
+//
+//   - skip leaves them untouched.
+//   - update compares the tweet text, media and author display name and, if
+//     any changed, keeps the stored version as a revision before updating.
+//   - replace does the same and also overwrites the author username, tweet
+//     URL and bookmark date with the imported values.
+//
+// Bookmarks in the trash are restored as they were, in every mode, and count
+// as inserted: re-importing a tweet brings it back. When a tweet appears
+// more than once in the batch, its first occurrence is used.
+const importMergeQuery = `
+	WITH staged AS (
+		SELECT DISTINCT ON (tweet_id) *
+		FROM import_staging
+		ORDER BY tweet_id, idx
+	),
+	existing AS (
+		SELECT b.id, s.idx, b.tweet_text, b.author_display_name, b.media_urls,
+		       b.title, b.lang, b.bookmarked_at, b.notes, b.metadata, b.author_username, b.tweet_url,
+		       (COALESCE(b.tweet_text, '') <> COALESCE(s.tweet_text, '')
+		        OR COALESCE(b.author_display_name, '') <> COALESCE(s.author_display_name, '')
+		        OR COALESCE(b.media_urls, '{}') <> COALESCE(s.media_urls, '{}')) AS content_changed,
+		       (COALESCE(b.author_username, '') <> COALESCE(s.author_username, '')
+		        OR COALESCE(b.tweet_url, '') <> COALESCE(s.tweet_url, '')
+		        OR b.bookmarked_at IS DISTINCT FROM s.bookmarked_at) AS source_changed
+		FROM bookmarks b
+		JOIN staged s ON s.tweet_id = b.tweet_id
+		WHERE b.user_id = $1 AND b.deleted_at IS NULL AND $2::text <> 'skip'
+		FOR UPDATE OF b
+	),
+	changed AS (
+		SELECT * FROM existing
+		WHERE content_changed OR ($2::text = 'replace' AND source_changed)
+	),
+	revisions AS (
+		INSERT INTO bookmark_revisions (bookmark_id, tweet_text, author_display_name, media_urls)
+		SELECT id, COALESCE(tweet_text, ''), COALESCE(author_display_name, ''), media_urls
+		FROM changed
+		WHERE content_changed
+	),
+	updated AS (
+		UPDATE bookmarks b
+		SET tweet_text = s.tweet_text, author_display_name = s.author_display_name, media_urls = s.media_urls,
+		    lang = s.lang, search_config = s.search_config::regconfig, updated_at = NOW(),
+		    author_username = CASE WHEN $2::text = 'replace' THEN s.author_username ELSE b.author_username END,
+		    tweet_url = CASE WHEN $2::text = 'replace' THEN s.tweet_url ELSE b.tweet_url END,
+		    bookmarked_at = CASE WHEN $2::text = 'replace' THEN s.bookmarked_at ELSE b.bookmarked_at END
+		FROM changed c
+		JOIN staged s ON s.idx = c.idx
+		WHERE b.id = c.id
+		RETURNING c.idx, c.id, c.title, COALESCE(c.tweet_text, '') AS tweet_text, c.lang,
+		          c.bookmarked_at, c.notes, c.metadata, COALESCE(c.author_username, '') AS author_username,
+		          COALESCE(c.author_display_name, '') AS author_display_name,
+		          COALESCE(c.tweet_url, '') AS tweet_url, COALESCE(c.media_urls, '{}') AS media_urls
+	),
+	restored AS (
+		UPDATE bookmarks b
+		SET deleted_at = NULL
+		FROM staged s
+		WHERE b.user_id = $1 AND b.tweet_id = s.tweet_id AND b.deleted_at IS NOT NULL
+		RETURNING s.idx
+	),
+	inserted AS (
+		INSERT INTO bookmarks (user_id, tweet_id, tweet_text, author_username, author_display_name, tweet_url,
+		                       media_urls, bookmarked_at, lang, search_config)
+		SELECT $1::uuid, s.tweet_id, s.tweet_text, s.author_username, s.author_display_name, s.tweet_url,
+		       s.media_urls, s.bookmarked_at, s.lang, s.search_config::regconfig
+		FROM staged s
+		WHERE NOT EXISTS (SELECT 1 FROM bookmarks b WHERE b.user_id = $1 AND b.tweet_id = s.tweet_id)
+		ON CONFLICT (user_id, tweet_id) DO NOTHING
+		RETURNING tweet_id
+	)
+	SELECT idx, 'updated', id, title, tweet_text, lang, bookmarked_at, notes, metadata,
+	       author_username, author_display_name, tweet_url, media_urls
+	FROM updated
+	UNION ALL
+	SELECT idx, 'inserted', NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL
+	FROM restored
+	UNION ALL
+	SELECT s.idx, 'inserted', NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL
+	FROM inserted i JOIN staged s ON s.tweet_id = i.tweet_id
+`
+
+// ImportResult is what ImportBookmarks did with a batch.
+type ImportResult struct {
+	// Outcomes holds one outcome per bookmark.
+	Outcomes []string
+	// CreatedCategories are the categories created for names that matched
+	// none of the user's categories.
+	CreatedCategories []uuid.UUID
+	// Assignments are the category assignments the import added.
+	Assignments []models.BookmarkCategory
+	// Edits hold the updated bookmarks as they were before the import.
+	Edits []models.BookmarkSnapshot
+}
+
+// ImportBookmarks imports a batch of bookmarks for the user holding lock in
+// one transaction: the batch is copied into a temporary staging table and
+// merged into bookmarks with a single statement (see importMergeQuery), then
+// each bookmark is assigned to the categories listed in its Categories.
+// Either the whole batch is applied or nothing is. It sets each bookmark's
+// ID to the new or existing bookmark's id. The lock keeps other imports of
+// the user out until the whole import is done.
+func ImportBookmarks(ctx context.Context, lock *ImportLock, bookmarks []*models.Bookmark, mode string) (*ImportResult, error) {
+	userID := lock.userID
+	outcomes := make([]string, len(bookmarks))
+	result := &ImportResult{Outcomes: outcomes}
+	if len(bookmarks) == 0 {
+		return result, nil
+	}
+
+	tx, err := lock.conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		CREATE TEMP TABLE import_staging (
+			idx INT NOT NULL,
+			tweet_id TEXT NOT NULL,
+			tweet_text TEXT,
+			author_username TEXT,
+			author_display_name TEXT,
+			tweet_url TEXT,
+			media_urls TEXT[],
+			bookmarked_at TIMESTAMP,
+			lang TEXT NOT NULL,
+			search_config TEXT NOT NULL
+		) ON COMMIT DROP
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([][]interface{}, len(bookmarks))
+	for i, b := range bookmarks {
+		if b.Lang == "" {
+			b.Lang = langdetect.Undetermined
+		}
+		rows[i] = []interface{}{
+			i, b.TweetID, b.TweetText, b.AuthorUsername, b.AuthorDisplayName,
+			b.TweetURL, b.MediaURLs, b.BookmarkedAt, b.Lang, langdetect.SearchConfig(b.Lang),
+		}
+	}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"import_staging"}, importStagingColumns, pgx.CopyFromRows(rows)); err != nil {
+		return nil, err
+	}
+
+	merged, err := tx.Query(ctx, importMergeQuery, userID, mode)
+	if err != nil {
+		return nil, err
+	}
+	for merged.Next() {
+		var idx int
+		var outcome string
+		var id *uuid.UUID
+		var title, tweetText, lang, notes *string
+		var bookmarkedAt *time.Time
+		var snap models.BookmarkSnapshot
+		err := merged.Scan(&idx, &outcome, &id, &title, &tweetText, &lang, &bookmarkedAt, &notes, &snap.Metadata,
+			&snap.AuthorUsername, &snap.AuthorDisplayName, &snap.TweetURL, &snap.MediaURLs)
+		if err != nil {
+			merged.Close()
+			return nil, err
+		}
+		outcomes[idx] = outcome
+		if id != nil {
+			snap.ID, snap.Title, snap.TweetText, snap.Lang, snap.BookmarkedAt, snap.Notes =
+				*id, *title, *tweetText, *lang, *bookmarkedAt, *notes
+			if snap.MediaURLs != nil && *snap.MediaURLs == nil {
+				*snap.MediaURLs = []string{}
+			}
+			result.Edits = append(result.Edits, snap)
+		}
+	}
+	merged.Close()
+	if err := merged.Err(); err != nil {
+		return nil, err
+	}
+
+	// Everything the merge did not insert or update was already there.
+	ids, err := tx.Query(ctx, `
+		SELECT s.idx, b.id
+		FROM import_staging s
+		JOIN bookmarks b ON b.user_id = $1 AND b.tweet_id = s.tweet_id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer ids.Close()
+	for ids.Next() {
+		var idx int
+		var id uuid.UUID
+		if err := ids.Scan(&idx, &id); err != nil {
+			return nil, err
+		}
+		bookmarks[idx].ID = id
+		if outcomes[idx] == "" {
+			outcomes[idx] = models.ImportOutcomeUnchanged
+		}
+	}
+	if err := ids.Err(); err != nil {
+		return nil, err
+	}
+	ids.Close()
+
+	if err := assignImportCategories(ctx, tx, userID, bookmarks, result); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// assignImportCategories assigns imported bookmarks to the categories named
+// in their Categories. Names match the user's categories case-insensitively;
+// missing ones are created with the color and icon given in the first
+// bookmark that names them.
+func assignImportCategories(ctx context.Context, tx pgx.Tx, userID uuid.UUID, bookmarks []*models.Bookmark, result *ImportResult) error {
+	wanted := make(map[string]models.Category)
+	var keys []string
+	for _, b := range bookmarks {
+		for _, cat := range b.Categories {
+			key := strings.ToLower(cat.Name)
+			if _, ok := wanted[key]; !ok {
+				wanted[key] = cat
+				keys = append(keys, key)
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT DISTINCT ON (LOWER(name)) LOWER(name), id
+		FROM categories
+		WHERE user_id = $1 AND deleted_at IS NULL AND LOWER(name) = ANY($2)
+		ORDER BY LOWER(name), created_at
+	`, userID, keys)
+	if err != nil {
+		return err
+	}
+	categoryIDs := make(map[string]uuid.UUID, len(keys))
+	for rows.Next() {
+		var key string
+		var id uuid.UUID
+		if err := rows.Scan(&key, &id); err != nil {
+			rows.Close()
+			return err
+		}
+		categoryIDs[key] = id
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if _, exists := categoryIDs[key]; exists {
+			continue
+		}
+		cat := wanted[key]
+		var id uuid.UUID
+		err := tx.QueryRow(ctx, `
+			INSERT INTO categories (user_id, name, color, icon) VALUES ($1, $2, $3, $4) RETURNING id
+		`, userID, cat.Name, cat.Color, cat.Icon).Scan(&id)
+		if err != nil {
+			return err
+		}
+		categoryIDs[key] = id
+		result.CreatedCategories = append(result.CreatedCategories, id)
+	}
+
+	// A tweet listed twice in the batch maps to one bookmark.
+	seen := make(map[models.BookmarkCategory]bool)
+	var bookmarkIDs, assignIDs []uuid.UUID
+	for _, b := range bookmarks {
+		for _, cat := range b.Categories {
+			pair := models.BookmarkCategory{BookmarkID: b.ID, CategoryID: categoryIDs[strings.ToLower(cat.Name)]}
+			if !seen[pair] {
+				seen[pair] = true
+				bookmarkIDs = append(bookmarkIDs, pair.BookmarkID)
+				assignIDs = append(assignIDs, pair.CategoryID)
+			}
+		}
+	}
+
+	assigned, err := tx.Query(ctx, `
+		INSERT INTO bookmark_categories (bookmark_id, category_id)
+		SELECT * FROM UNNEST($1::uuid[], $2::uuid[])
+		ON CONFLICT (bookmark_id, category_id) DO NOTHING
+		RETURNING bookmark_id, category_id
+	`, bookmarkIDs, assignIDs)
+	if err != nil {
+		return err
+	}
+	defer assigned.Close()
+	for assigned.Next() {
+		var pair models.BookmarkCategory
+		if err := assigned.Scan(&pair.BookmarkID, &pair.CategoryID); err != nil {
+			return err
+		}
+		result.Assignments = append(result.Assignments, pair)
+	}
+	return assigned.Err()
+}
+
+// PreviewImport works out what ImportBookmarks would do with a batch without
+// writing anything, following the same rules as importMergeQuery. It returns
+// one outcome per bookmark and, for updates, the fields that would change.
+// Bookmarks the user already has get their ID set.
+func PreviewImport(ctx context.Context, userID uuid.UUID, bookmarks []*models.Bookmark, mode string) ([]string, [][]models.FieldChange, error) {
+	outcomes := make([]string, len(bookmarks))
+	changes := make([][]models.FieldChange, len(bookmarks))
+	if len(bookmarks) == 0 {
+		return outcomes, changes, nil
+	}
+
+	tweetIDs := make([]string, len(bookmarks))
+	for i, b := range bookmarks {
+		tweetIDs[i] = b.TweetID
+	}
+	rows, err := DB.Query(ctx, `
+		SELECT `+bookmarkColumns+`
+		FROM bookmarks b
+		WHERE b.user_id = $1 AND b.tweet_id = ANY($2)
+	`, userID, tweetIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	existing, err := collectBookmarks(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+	byTweet := make(map[string]models.Bookmark, len(existing))
+	for _, b := range existing {
+		byTweet[b.TweetID] = b
+	}
+
+	seen := make(map[string]bool, len(bookmarks))
+	for i, b := range bookmarks {
+		current, exists := byTweet[b.TweetID]
+		if exists {
+			b.ID = current.ID
+		}
+
+		// Only the first occurrence of a tweet in the batch is applied.
+		first := !seen[b.TweetID]
+		seen[b.TweetID] = true
+
+		switch {
+		case !first:
+			outcomes[i] = models.ImportOutcomeUnchanged
+		case !exists || current.DeletedAt != nil:
+			outcomes[i] = models.ImportOutcomeInserted
+		case mode == models.ImportModeSkip:
+			outcomes[i] = models.ImportOutcomeUnchanged
+		default:
+			changed := revisionChanges(current, *b)
+			if mode == models.ImportModeReplace {
+				changed = append(changed, sourceChanges(current, *b)...)
+			}
+			outcomes[i] = models.ImportOutcomeUnchanged
+			if len(changed) > 0 {
+				outcomes[i] = models.ImportOutcomeUpdated
+				changes[i] = changed
+			}
+		}
+	}
+	return outcomes, changes, nil
+}
+
+// sourceChanges lists the fields only replace mode overwrites that differ
+// between two versions of a bookmark.
+func sourceChanges(old, new models.Bookmark) []models.FieldChange {
+	var changes []models.FieldChange
+	if old.AuthorUsername != new.AuthorUsername {
+		changes = append(changes, models.FieldChange{Field: "author_username", Old: old.AuthorUsername, New: new.AuthorUsername})
+	}
+	if old.TweetURL != new.TweetURL {
+		changes = append(changes, models.FieldChange{Field: "tweet_url", Old: old.TweetURL, New: new.TweetURL})
+	}
+	// bookmarked_at is stored without a time zone and with microsecond
+	// precision; pgx writes the wall clock time as is.
+	t := new.BookmarkedAt
+	stored := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	if !old.BookmarkedAt.Equal(stored.Truncate(time.Microsecond)) {
+		changes = append(changes, models.FieldChange{Field: "bookmarked_at", Old: old.BookmarkedAt, New: new.BookmarkedAt})
+	}
+	return changes
+}
@@ -0,0 +1,173 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+	"twitter-bookmarks-api/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobFinished = errors.New("job has already finished")
+)
+
+const importJobColumns = `
+	id, user_id, status, mode, source, total, processed, imported_count, updated_count,
+	duplicate_count, failed_count, auto_categorized, errors, operation_id,
+	COALESCE(error, ''), cancel_requested, created_at, started_at, finished_at
+`
+
+func scanImportJob(row pgx.Row, job *models.ImportJob, extra ...interface{}) error {
+	dest := []interface{}{
+		&job.ID, &job.UserID, &job.Status, &job.Mode, &job.Source, &job.Total, &job.Processed,
+		&job.ImportedCount, &job.UpdatedCount, &job.DuplicateCount, &job.FailedCount,
+		&job.AutoCategorized, &job.Errors, &job.OperationID, &job.Error,
+		&job.CancelRequested, &job.CreatedAt, &job.StartedAt, &job.FinishedAt,
+	}
+	return row.Scan(append(dest, extra...)...)
+}
+
+// CreateImportJob queues items for import in the background.
+func CreateImportJob(ctx context.Context, userID uuid.UUID, mode, source string, items []models.BookmarkImportItem) (*models.ImportJob, error) {
+	job := &models.ImportJob{}
+	query := `
+		INSERT INTO import_jobs (user_id, mode, source, items, total)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + importJobColumns
+	err := scanImportJob(DB.QueryRow(ctx, query, userID, mode, source, items, len(items)), job)
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// ClaimImportJob marks the oldest queued job as running and returns it with
+// its items, or nil when no job is queued. SKIP LOCKED keeps concurrent
+// workers from claiming the same job. The worker must keep the job's
+// heartbeat fresh with TouchImportJob while it runs.
+func ClaimImportJob(ctx context.Context) (*models.ImportJob, []models.BookmarkImportItem, error) {
+	job := &models.ImportJob{}
+	var items []models.BookmarkImportItem
+	query := `
+		UPDATE import_jobs
+		SET status = $1, started_at = NOW(), heartbeat_at = NOW()
+		WHERE id = (
+			SELECT id FROM import_jobs
+			WHERE status = $2
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + importJobColumns + `, items`
+	err := scanImportJob(DB.QueryRow(ctx, query, models.JobStatusRunning, models.JobStatusQueued), job, &items)
+	if err == pgx.ErrNoRows {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return job, items, nil
+}
+
+// UpdateImportJobProgress saves the job's counters and reports whether the
+// worker should stop: the user asked to cancel the job, or it is no longer
+// running because its heartbeat went stale.
+func UpdateImportJobProgress(ctx context.Context, job *models.ImportJob) (bool, error) {
+	query := `
+		UPDATE import_jobs
+		SET processed = $1, imported_count = $2, updated_count = $3, duplicate_count = $4,
+		    failed_count = $5, auto_categorized = $6, errors = $7, heartbeat_at = NOW()
+		WHERE id = $8 AND status = $9
+		RETURNING cancel_requested
+	`
+	var cancelRequested bool
+	err := DB.QueryRow(ctx, query, job.Processed, job.ImportedCount, job.UpdatedCount, job.DuplicateCount,
+		job.FailedCount, job.AutoCategorized, job.Errors, job.ID, models.JobStatusRunning).Scan(&cancelRequested)
+	if err == pgx.ErrNoRows {
+		return true, nil
+	}
+	return cancelRequested, err
+}
+
+// TouchImportJob refreshes the heartbeat of a running job, showing that its
+// worker is still alive.
+func TouchImportJob(ctx context.Context, jobID uuid.UUID) error {
+	_, err := DB.Exec(ctx, `UPDATE import_jobs SET heartbeat_at = NOW() WHERE id = $1 AND status = $2`,
+		jobID, models.JobStatusRunning)
+	return err
+}
+
+// FinishImportJob records the job's final state and drops its items. A job
+// that was already failed as abandoned is left as it is.
+func FinishImportJob(ctx context.Context, job *models.ImportJob) error {
+	query := `
+		UPDATE import_jobs
+		SET status = $1, processed = $2, imported_count = $3, updated_count = $4, duplicate_count = $5,
+		    failed_count = $6, auto_categorized = $7, errors = $8, operation_id = $9, error = NULLIF($10, ''),
+		    items = NULL, finished_at = NOW()
+		WHERE id = $11 AND status = $12
+	`
+	_, err := DB.Exec(ctx, query, job.Status, job.Processed, job.ImportedCount, job.UpdatedCount,
+		job.DuplicateCount, job.FailedCount, job.AutoCategorized, job.Errors, job.OperationID, job.Error, job.ID,
+		models.JobStatusRunning)
+	return err
+}
+
+func GetImportJob(ctx context.Context, jobID, userID uuid.UUID) (*models.ImportJob, error) {
+	job := &models.ImportJob{}
+	query := `SELECT ` + importJobColumns + ` FROM import_jobs WHERE id = $1 AND user_id = $2`
+	err := scanImportJob(DB.QueryRow(ctx, query, jobID, userID), job)
+	if err == pgx.ErrNoRows {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// CancelImportJob cancels a queued job right away. A running job is only
+// flagged; the worker stops it after the batch it is working on.
+func CancelImportJob(ctx context.Context, jobID, userID uuid.UUID) (*models.ImportJob, error) {
+	job := &models.ImportJob{}
+	query := `
+		UPDATE import_jobs
+		SET cancel_requested = TRUE,
+		    status = CASE WHEN status = $3 THEN $4 ELSE status END,
+		    finished_at = CASE WHEN status = $3 THEN NOW() ELSE finished_at END,
+		    items = CASE WHEN status = $3 THEN NULL ELSE items END
+		WHERE id = $1 AND user_id = $2 AND status IN ($3, $5)
+		RETURNING ` + importJobColumns
+	err := scanImportJob(DB.QueryRow(ctx, query, jobID, userID,
+		models.JobStatusQueued, models.JobStatusCancelled, models.JobStatusRunning), job)
+	if err == pgx.ErrNoRows {
+		if _, err := GetImportJob(ctx, jobID, userID); err != nil {
+			return nil, err
+		}
+		return nil, ErrJobFinished
+	}
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// FailAbandonedImportJobs marks running jobs whose heartbeat is older than
+// timeout as failed: their worker stopped without finishing them, such
+// as in a crash. Jobs other workers are running keep a fresh heartbeat and
+// are left alone. The finished batches of a failed job are kept.
+func FailAbandonedImportJobs(ctx context.Context, timeout time.Duration) (int64, error) {
+	result, err := DB.Exec(ctx, `
+		UPDATE import_jobs
+		SET status = $1, error = 'interrupted: the worker running it stopped', items = NULL, finished_at = NOW()
+		WHERE status = $2 AND COALESCE(heartbeat_at, started_at) < NOW() - make_interval(secs => $3)
+	`, models.JobStatusFailed, models.JobStatusRunning, timeout.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
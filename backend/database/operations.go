@@ -0,0 +1,171 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+	"twitter-bookmarks-api/langdetect"
+	"twitter-bookmarks-api/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrOperationNotFound = errors.New("operation not found")
+	ErrOperationUndone   = errors.New("operation already undone")
+	ErrOperationExpired  = errors.New("undo window has expired")
+)
+
+// RecordOperation adds an entry to the user's operation log and returns its id.
+func RecordOperation(ctx context.Context, userID uuid.UUID, kind string, inverse models.OperationInverse) (uuid.UUID, error) {
+	return recordOperation(ctx, DB, userID, kind, inverse)
+}
+
+func recordOperation(ctx context.Context, q querier, userID uuid.UUID, kind string, inverse models.OperationInverse) (uuid.UUID, error) {
+	var id uuid.UUID
+	query := `INSERT INTO operations (user_id, kind, inverse) VALUES ($1, $2, $3) RETURNING id`
+	err := q.QueryRow(ctx, query, userID, kind, inverse).Scan(&id)
+	return id, err
+}
+
+// UndoOperation applies the inverse of an operation recorded less than
+// window ago and marks it as undone, all in one transaction.
+func UndoOperation(ctx context.Context, operationID, userID uuid.UUID, window time.Duration) (*models.Operation, error) {
+	tx, err := DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	op := &models.Operation{}
+	query := `
+		SELECT id, user_id, kind, inverse, created_at, undone_at
+		FROM operations
+		WHERE id = $1 AND user_id = $2
+		FOR UPDATE
+	`
+	err = tx.QueryRow(ctx, query, operationID, userID).Scan(
+		&op.ID, &op.UserID, &op.Kind, &op.Inverse, &op.CreatedAt, &op.UndoneAt,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, ErrOperationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if op.UndoneAt != nil {
+		return nil, ErrOperationUndone
+	}
+	if time.Since(op.CreatedAt) > window {
+		return nil, ErrOperationExpired
+	}
+
+	if err := applyInverse(ctx, tx, userID, op.Inverse); err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRow(ctx, `UPDATE operations SET undone_at = NOW() WHERE id = $1 RETURNING undone_at`, op.ID).Scan(&op.UndoneAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+// applyInverse runs every change in inv, restricted to rows owned by userID.
+// Assignment changes run first so that categories trashed afterwards keep
+// the assignments they had when the operation happened.
+func applyInverse(ctx context.Context, q querier, userID uuid.UUID, inv models.OperationInverse) error {
+	if len(inv.RemoveAssignments) > 0 {
+		bookmarkIDs, categoryIDs := splitAssignments(inv.RemoveAssignments)
+		_, err := q.Exec(ctx, `
+			DELETE FROM bookmark_categories bc
+			USING unnest($1::uuid[], $2::uuid[]) AS a(bookmark_id, category_id), bookmarks b
+			WHERE bc.bookmark_id = a.bookmark_id AND bc.category_id = a.category_id
+			  AND b.id = bc.bookmark_id AND b.user_id = $3
+		`, bookmarkIDs, categoryIDs, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(inv.AddAssignments) > 0 {
+		bookmarkIDs, categoryIDs := splitAssignments(inv.AddAssignments)
+		_, err := q.Exec(ctx, `
+			INSERT INTO bookmark_categories (bookmark_id, category_id)
+			SELECT a.bookmark_id, a.category_id
+			FROM unnest($1::uuid[], $2::uuid[]) AS a(bookmark_id, category_id)
+			JOIN bookmarks b ON b.id = a.bookmark_id AND b.user_id = $3
+			JOIN categories c ON c.id = a.category_id AND c.user_id = $3
+			ON CONFLICT (bookmark_id, category_id) DO NOTHING
+		`, bookmarkIDs, categoryIDs, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	statements := []struct {
+		ids   []uuid.UUID
+		query string
+	}{
+		{inv.UnarchiveBookmarks, `UPDATE bookmarks SET archived_at = NULL, updated_at = NOW() WHERE id = ANY($1) AND user_id = $2`},
+		{inv.MarkUnread, `UPDATE bookmarks SET read_at = NULL, updated_at = NOW() WHERE id = ANY($1) AND user_id = $2`},
+		{inv.TrashBookmarks, `UPDATE bookmarks SET deleted_at = NOW() WHERE id = ANY($1) AND user_id = $2 AND deleted_at IS NULL`},
+		{inv.TrashCategories, `UPDATE categories SET deleted_at = NOW() WHERE id = ANY($1) AND user_id = $2 AND deleted_at IS NULL`},
+		{inv.RestoreBookmarks, `UPDATE bookmarks SET deleted_at = NULL WHERE id = ANY($1) AND user_id = $2`},
+		{inv.RestoreCategories, `UPDATE categories SET deleted_at = NULL WHERE id = ANY($1) AND user_id = $2`},
+	}
+	for _, stmt := range statements {
+		if len(stmt.ids) == 0 {
+			continue
+		}
+		if _, err := q.Exec(ctx, stmt.query, stmt.ids, userID); err != nil {
+			return err
+		}
+	}
+
+	for _, snap := range inv.BookmarkEdits {
+		if snap.Metadata == nil {
+			snap.Metadata = map[string]interface{}{}
+		}
+		_, err := q.Exec(ctx, `
+			UPDATE bookmarks
+			SET title = $1, tweet_text = $2, lang = $3, search_config = $4::regconfig,
+			    bookmarked_at = $5, notes = $6, metadata = $7, updated_at = NOW(),
+			    author_username = COALESCE($10, author_username),
+			    author_display_name = COALESCE($11, author_display_name),
+			    tweet_url = COALESCE($12, tweet_url),
+			    media_urls = COALESCE($13::text[], media_urls)
+			WHERE id = $8 AND user_id = $9
+		`, snap.Title, snap.TweetText, snap.Lang, langdetect.SearchConfig(snap.Lang),
+			snap.BookmarkedAt, snap.Notes, snap.Metadata, snap.ID, userID,
+			snap.AuthorUsername, snap.AuthorDisplayName, snap.TweetURL, snap.MediaURLs)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, cat := range inv.CategoryEdits {
+		_, err := q.Exec(ctx, `UPDATE categories SET name = $1, color = $2, icon = $3 WHERE id = $4 AND user_id = $5`,
+			cat.Name, cat.Color, cat.Icon, cat.ID, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func splitAssignments(assignments []models.BookmarkCategory) ([]uuid.UUID, []uuid.UUID) {
+	bookmarkIDs := make([]uuid.UUID, len(assignments))
+	categoryIDs := make([]uuid.UUID, len(assignments))
+	for i, a := range assignments {
+		bookmarkIDs[i] = a.BookmarkID
+		categoryIDs[i] = a.CategoryID
+	}
+	return bookmarkIDs, categoryIDs
+}
@@ -2,13 +2,18 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"twitter-bookmarks-api/langdetect"
 	"twitter-bookmarks-api/models"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 )
 
+var ErrBookmarkNotFound = errors.New("bookmark not found")
+
 func CreateUser(ctx context.Context, twitterID, username, displayName, profileImage string) (*models.User, error) {
 	user := &models.User{}
 	query := `
@@ -48,67 +53,108 @@ func GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error) {
 	return user, err
 }
 
-func CreateBookmark(ctx context.Context, bookmark *models.Bookmark) error {
-	query := `
-		INSERT INTO bookmarks (user_id, tweet_id, tweet_text, author_username, author_display_name, tweet_url, media_urls, bookmarked_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		ON CONFLICT (user_id, tweet_id) DO NOTHING
-		RETURNING id, created_at
-	`
-	return DB.QueryRow(ctx, query,
-		bookmark.UserID, bookmark.TweetID, bookmark.TweetText, bookmark.AuthorUsername,
-		bookmark.AuthorDisplayName, bookmark.TweetURL, bookmark.MediaURLs, bookmark.BookmarkedAt,
-	).Scan(&bookmark.ID, &bookmark.CreatedAt)
+// bookmarkColumns is the column list scanned by scanBookmark. Queries using it
+// must alias the bookmarks table as b.
+const bookmarkColumns = `b.id, b.user_id, b.tweet_id, b.tweet_text, b.author_username,
+		b.author_display_name, b.tweet_url, b.media_urls, b.title, b.notes, b.metadata,
+		b.bookmarked_at, b.created_at, b.updated_at, b.lang, b.status, b.status_checked_at,
+		b.archived_at, b.read_at, b.deleted_at`
+
+// scanBookmark scans a row selected with bookmarkColumns into b. Any extra
+// destinations are scanned from the columns that follow bookmarkColumns.
+func scanBookmark(row pgx.Row, b *models.Bookmark, extra ...interface{}) error {
+	dest := []interface{}{&b.ID, &b.UserID, &b.TweetID, &b.TweetText, &b.AuthorUsername,
+		&b.AuthorDisplayName, &b.TweetURL, &b.MediaURLs, &b.Title, &b.Notes, &b.Metadata,
+		&b.BookmarkedAt, &b.CreatedAt, &b.UpdatedAt, &b.Lang, &b.Status, &b.StatusCheckedAt,
+		&b.ArchivedAt, &b.ReadAt, &b.DeletedAt}
+	return row.Scan(append(dest, extra...)...)
 }
 
-func GetBookmarksByUserID(ctx context.Context, userID uuid.UUID, params models.PaginationParams, categoryID *uuid.UUID) (*models.BookmarksResponse, error) {
+func collectBookmarks(rows pgx.Rows) ([]models.Bookmark, error) {
 	var bookmarks []models.Bookmark
-	var total int
+	for rows.Next() {
+		var b models.Bookmark
+		if err := scanBookmark(rows, &b); err != nil {
+			return nil, err
+		}
+		bookmarks = append(bookmarks, b)
+	}
+	return bookmarks, rows.Err()
+}
 
-	countQuery := `SELECT COUNT(*) FROM bookmarks WHERE user_id = $1`
-	baseQuery := `
-		SELECT DISTINCT b.id, b.user_id, b.tweet_id, b.tweet_text, b.author_username, 
-		       b.author_display_name, b.tweet_url, b.media_urls, b.bookmarked_at, b.created_at
-		FROM bookmarks b
-	`
+// appendBookmarkFilter extends a WHERE clause over bookmarks b with the
+// conditions in filter, numbering new placeholders after the existing args.
+func appendBookmarkFilter(where string, args []interface{}, filter models.BookmarkFilter) (string, []interface{}) {
+	if filter.CategoryID != nil {
+		args = append(args, *filter.CategoryID)
+		where += fmt.Sprintf(" AND b.id IN (SELECT bookmark_id FROM bookmark_categories WHERE category_id = $%d)", len(args))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		where += fmt.Sprintf(" AND b.status = $%d", len(args))
+	}
+	if filter.Lang != "" {
+		args = append(args, filter.Lang)
+		where += fmt.Sprintf(" AND b.lang = $%d", len(args))
+	}
+	if filter.Archived != nil {
+		if *filter.Archived {
+			where += " AND b.archived_at IS NOT NULL"
+		} else {
+			where += " AND b.archived_at IS NULL"
+		}
+	}
+	if filter.Read != nil {
+		if *filter.Read {
+			where += " AND b.read_at IS NOT NULL"
+		} else {
+			where += " AND b.read_at IS NULL"
+		}
+	}
+	return where, args
+}
 
-	countArgs := []interface{}{userID}
-	queryArgs := []interface{}{userID}
-	whereClauses := "WHERE b.user_id = $1"
+// queryBookmarksPage runs a paginated bookmark listing for the given WHERE
+// clause narrowed by filter, attaches each bookmark's categories and computes
+// the language facet.
+func queryBookmarksPage(ctx context.Context, where string, args []interface{}, filter models.BookmarkFilter, params models.PaginationParams) (*models.BookmarksResponse, error) {
+	var total int
 
-	if categoryID != nil {
-		baseQuery += " LEFT JOIN bookmark_categories bc ON b.id = bc.bookmark_id"
-		whereClauses += " AND bc.category_id = $2"
-		countQuery += " AND id IN (SELECT bookmark_id FROM bookmark_categories WHERE category_id = $2)"
-		countArgs = append(countArgs, *categoryID)
-		queryArgs = append(queryArgs, *categoryID)
-	}
+	// The language facet ignores the language filter so that clients can
+	// offer switching to another language.
+	facetFilter := filter
+	facetFilter.Lang = ""
+	facetWhere, facetArgs := appendBookmarkFilter(where, append([]interface{}{}, args...), facetFilter)
+	where, args = appendBookmarkFilter(where, args, filter)
 
-	err := DB.QueryRow(ctx, countQuery, countArgs...).Scan(&total)
+	countQuery := "SELECT COUNT(*) FROM bookmarks b " + where
+	err := DB.QueryRow(ctx, countQuery, args...).Scan(&total)
 	if err != nil {
 		return nil, err
 	}
 
-	fullQuery := fmt.Sprintf("%s %s ORDER BY b.bookmarked_at DESC LIMIT $%d OFFSET $%d",
-		baseQuery, whereClauses, len(queryArgs)+1, len(queryArgs)+2)
-	queryArgs = append(queryArgs, params.PageSize, params.Offset)
+	fullQuery := fmt.Sprintf("SELECT %s FROM bookmarks b %s ORDER BY b.bookmarked_at DESC LIMIT $%d OFFSET $%d",
+		bookmarkColumns, where, len(args)+1, len(args)+2)
+	queryArgs := append(args, params.PageSize, params.Offset)
 
 	rows, err := DB.Query(ctx, fullQuery, queryArgs...)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	bookmarks, err := collectBookmarks(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
 
-	for rows.Next() {
-		var b models.Bookmark
-		err := rows.Scan(&b.ID, &b.UserID, &b.TweetID, &b.TweetText, &b.AuthorUsername,
-			&b.AuthorDisplayName, &b.TweetURL, &b.MediaURLs, &b.BookmarkedAt, &b.CreatedAt)
-		if err != nil {
-			return nil, err
-		}
-		categories, _ := GetCategoriesByBookmarkID(ctx, b.ID)
-		b.Categories = categories
-		bookmarks = append(bookmarks, b)
+	for i := range bookmarks {
+		categories, _ := GetCategoriesByBookmarkID(ctx, bookmarks[i].ID)
+		bookmarks[i].Categories = categories
+	}
+
+	languages, err := getLanguageFacet(ctx, facetWhere, facetArgs)
+	if err != nil {
+		return nil, err
 	}
 
 	totalPages := (total + params.PageSize - 1) / params.PageSize
@@ -118,69 +164,165 @@ func GetBookmarksByUserID(ctx context.Context, userID uuid.UUID, params models.P
 		Page:       params.Page,
 		PageSize:   params.PageSize,
 		TotalPages: totalPages,
+		Facets:     &models.BookmarkFacets{Languages: languages},
 	}, nil
 }
 
-func SearchBookmarks(ctx context.Context, userID uuid.UUID, query string, params models.PaginationParams) (*models.BookmarksResponse, error) {
-	var bookmarks []models.Bookmark
-	var total int
-
-	searchPattern := "%" + query + "%"
-	countQuery := `
-		SELECT COUNT(*) FROM bookmarks 
-		WHERE user_id = $1 AND (tweet_text ILIKE $2 OR author_username ILIKE $2 OR author_display_name ILIKE $2)
-	`
-	err := DB.QueryRow(ctx, countQuery, userID, searchPattern).Scan(&total)
-	if err != nil {
-		return nil, err
-	}
-
-	searchQuery := `
-		SELECT id, user_id, tweet_id, tweet_text, author_username, author_display_name, 
-		       tweet_url, media_urls, bookmarked_at, created_at
-		FROM bookmarks
-		WHERE user_id = $1 AND (tweet_text ILIKE $2 OR author_username ILIKE $2 OR author_display_name ILIKE $2)
-		ORDER BY bookmarked_at DESC
-		LIMIT $3 OFFSET $4
-	`
-	rows, err := DB.Query(ctx, searchQuery, userID, searchPattern, params.PageSize, params.Offset)
+func getLanguageFacet(ctx context.Context, where string, args []interface{}) ([]models.FacetCount, error) {
+	query := "SELECT b.lang, COUNT(*) FROM bookmarks b " + where + " GROUP BY b.lang ORDER BY COUNT(*) DESC, b.lang"
+	rows, err := DB.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	facet := []models.FacetCount{}
 	for rows.Next() {
-		var b models.Bookmark
-		err := rows.Scan(&b.ID, &b.UserID, &b.TweetID, &b.TweetText, &b.AuthorUsername,
-			&b.AuthorDisplayName, &b.TweetURL, &b.MediaURLs, &b.BookmarkedAt, &b.CreatedAt)
-		if err != nil {
+		var f models.FacetCount
+		if err := rows.Scan(&f.Value, &f.Count); err != nil {
 			return nil, err
 		}
-		categories, _ := GetCategoriesByBookmarkID(ctx, b.ID)
-		b.Categories = categories
-		bookmarks = append(bookmarks, b)
+		facet = append(facet, f)
 	}
+	return facet, rows.Err()
+}
 
-	totalPages := (total + params.PageSize - 1) / params.PageSize
-	return &models.BookmarksResponse{
-		Bookmarks:  bookmarks,
-		Total:      total,
-		Page:       params.Page,
-		PageSize:   params.PageSize,
-		TotalPages: totalPages,
-	}, nil
+func GetBookmarksByUserID(ctx context.Context, userID uuid.UUID, params models.PaginationParams, filter models.BookmarkFilter) (*models.BookmarksResponse, error) {
+	return queryBookmarksPage(ctx, "WHERE b.user_id = $1 AND b.deleted_at IS NULL", []interface{}{userID}, filter, params)
 }
 
-func DeleteBookmark(ctx context.Context, bookmarkID, userID uuid.UUID) error {
-	query := `DELETE FROM bookmarks WHERE id = $1 AND user_id = $2`
-	result, err := DB.Exec(ctx, query, bookmarkID, userID)
+func SearchBookmarks(ctx context.Context, userID uuid.UUID, query string, params models.PaginationParams, filter models.BookmarkFilter) (*models.BookmarksResponse, error) {
+	where, args := appendSearchQuery("WHERE b.user_id = $1 AND b.deleted_at IS NULL", []interface{}{userID}, query)
+	return queryBookmarksPage(ctx, where, args, filter, params)
+}
+
+// appendSearchQuery extends a WHERE clause over bookmarks b to match the
+// free-text search query. Full-text matching uses each bookmark's own
+// language configuration, so stemming applies; ILIKE keeps substring and
+// author matches working.
+func appendSearchQuery(where string, args []interface{}, query string) (string, []interface{}) {
+	if query == "" {
+		return where, args
+	}
+	args = append(args, "%"+query+"%", query)
+	pattern, text := len(args)-1, len(args)
+	where += fmt.Sprintf(` AND (b.search_vector @@ websearch_to_tsquery(b.search_config, $%d)
+		OR b.tweet_text ILIKE $%d OR b.author_username ILIKE $%d OR b.author_display_name ILIKE $%d)`,
+		text, pattern, pattern, pattern)
+	return where, args
+}
+
+// DeleteBookmark moves a bookmark to the trash. Its category assignments are
+// kept so that restoring it brings them back; PurgeTrash removes it for good.
+func DeleteBookmark(ctx context.Context, bookmarkID, userID uuid.UUID) (uuid.UUID, error) {
+	tx, err := DB.Begin(ctx)
 	if err != nil {
-		return err
+		return uuid.Nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `UPDATE bookmarks SET deleted_at = NOW() WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`
+	result, err := tx.Exec(ctx, query, bookmarkID, userID)
+	if err != nil {
+		return uuid.Nil, err
 	}
 	if result.RowsAffected() == 0 {
-		return fmt.Errorf("bookmark not found")
+		return uuid.Nil, ErrBookmarkNotFound
 	}
-	return nil
+
+	operationID, err := recordOperation(ctx, tx, userID, models.OperationDeleteBookmark, models.OperationInverse{
+		RestoreBookmarks: []uuid.UUID{bookmarkID},
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return uuid.Nil, err
+	}
+	return operationID, nil
+}
+
+// UpdateBookmark applies a partial update to a bookmark and bumps its
+// updated_at. Changing the tweet text also switches the search configuration
+// to the language detected for the new text. The bookmark is locked while
+// its previous content is read, and the update is recorded in the operation
+// log within the same transaction.
+func UpdateBookmark(ctx context.Context, bookmarkID, userID uuid.UUID, update models.BookmarkUpdate) (uuid.UUID, error) {
+	tx, err := DB.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var previous models.Bookmark
+	err = scanBookmark(tx.QueryRow(ctx, `
+		SELECT `+bookmarkColumns+`
+		FROM bookmarks b
+		WHERE b.id = $1 AND b.user_id = $2 AND b.deleted_at IS NULL
+		FOR UPDATE
+	`, bookmarkID, userID), &previous)
+	if err == pgx.ErrNoRows {
+		return uuid.Nil, ErrBookmarkNotFound
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	var sets []string
+	var args []interface{}
+	set := func(column string, value interface{}) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if update.Title != nil {
+		set("title", *update.Title)
+	}
+	if update.TweetText != nil {
+		set("tweet_text", *update.TweetText)
+		set("lang", update.Lang)
+		args = append(args, langdetect.SearchConfig(update.Lang))
+		sets = append(sets, fmt.Sprintf("search_config = $%d::regconfig", len(args)))
+	}
+	if update.BookmarkedAt != nil {
+		set("bookmarked_at", *update.BookmarkedAt)
+	}
+	if update.Notes != nil {
+		set("notes", *update.Notes)
+	}
+	if update.Metadata != nil {
+		args = append(args, update.Metadata)
+		sets = append(sets, fmt.Sprintf("metadata = jsonb_strip_nulls(metadata || $%d::jsonb)", len(args)))
+	}
+	sets = append(sets, "updated_at = NOW()")
+
+	args = append(args, bookmarkID, userID)
+	query := fmt.Sprintf("UPDATE bookmarks SET %s WHERE id = $%d AND user_id = $%d",
+		strings.Join(sets, ", "), len(args)-1, len(args))
+	if _, err := tx.Exec(ctx, query, args...); err != nil {
+		return uuid.Nil, err
+	}
+
+	operationID, err := recordOperation(ctx, tx, userID, models.OperationUpdateBookmark, models.OperationInverse{
+		BookmarkEdits: []models.BookmarkSnapshot{{
+			ID:           previous.ID,
+			Title:        previous.Title,
+			TweetText:    previous.TweetText,
+			Lang:         previous.Lang,
+			BookmarkedAt: previous.BookmarkedAt,
+			Notes:        previous.Notes,
+			Metadata:     previous.Metadata,
+		}},
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return uuid.Nil, err
+	}
+	return operationID, nil
 }
 
 func CreateCategory(ctx context.Context, category *models.Category) error {
@@ -199,7 +341,8 @@ func GetCategoriesByUserID(ctx context.Context, userID uuid.UUID) ([]models.Cate
 		       COALESCE(COUNT(bc.bookmark_id), 0) as count
 		FROM categories c
 		LEFT JOIN bookmark_categories bc ON c.id = bc.category_id
-		WHERE c.user_id = $1
+		     AND bc.bookmark_id IN (SELECT id FROM bookmarks WHERE deleted_at IS NULL)
+		WHERE c.user_id = $1 AND c.deleted_at IS NULL
 		GROUP BY c.id
 		ORDER BY c.created_at DESC
 	`
@@ -226,7 +369,7 @@ func GetCategoriesByBookmarkID(ctx context.Context, bookmarkID uuid.UUID) ([]mod
 		SELECT c.id, c.user_id, c.name, c.color, c.icon, c.created_at
 		FROM categories c
 		INNER JOIN bookmark_categories bc ON c.id = bc.category_id
-		WHERE bc.bookmark_id = $1
+		WHERE bc.bookmark_id = $1 AND c.deleted_at IS NULL
 	`
 	rows, err := DB.Query(ctx, query, bookmarkID)
 	if err != nil {
@@ -246,9 +389,37 @@ func GetCategoriesByBookmarkID(ctx context.Context, bookmarkID uuid.UUID) ([]mod
 	return categories, nil
 }
 
+// GetCategoriesByBookmarkIDs returns the categories of several bookmarks in
+// one query, keyed by bookmark id.
+func GetCategoriesByBookmarkIDs(ctx context.Context, bookmarkIDs []uuid.UUID) (map[uuid.UUID][]models.Category, error) {
+	query := `
+		SELECT bc.bookmark_id, c.id, c.user_id, c.name, c.color, c.icon, c.created_at
+		FROM categories c
+		INNER JOIN bookmark_categories bc ON c.id = bc.category_id
+		WHERE bc.bookmark_id = ANY($1) AND c.deleted_at IS NULL
+	`
+	rows, err := DB.Query(ctx, query, bookmarkIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := make(map[uuid.UUID][]models.Category)
+	for rows.Next() {
+		var bookmarkID uuid.UUID
+		var c models.Category
+		err := rows.Scan(&bookmarkID, &c.ID, &c.UserID, &c.Name, &c.Color, &c.Icon, &c.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		categories[bookmarkID] = append(categories[bookmarkID], c)
+	}
+	return categories, rows.Err()
+}
+
 func GetCategoryByID(ctx context.Context, categoryID, userID uuid.UUID) (*models.Category, error) {
 	category := &models.Category{}
-	query := `SELECT id, user_id, name, color, icon, created_at FROM categories WHERE id = $1 AND user_id = $2`
+	query := `SELECT id, user_id, name, color, icon, created_at FROM categories WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`
 	err := DB.QueryRow(ctx, query, categoryID, userID).Scan(
 		&category.ID, &category.UserID, &category.Name, &category.Color, &category.Icon, &category.CreatedAt,
 	)
@@ -264,7 +435,7 @@ func UpdateCategory(ctx context.Context, categoryID, userID uuid.UUID, name, col
 		SET name = COALESCE(NULLIF($1, ''), name),
 		    color = COALESCE(NULLIF($2, ''), color),
 		    icon = COALESCE(NULLIF($3, ''), icon)
-		WHERE id = $4 AND user_id = $5
+		WHERE id = $4 AND user_id = $5 AND deleted_at IS NULL
 	`
 	result, err := DB.Exec(ctx, query, name, color, icon, categoryID, userID)
 	if err != nil {
@@ -276,8 +447,10 @@ func UpdateCategory(ctx context.Context, categoryID, userID uuid.UUID, name, col
 	return nil
 }
 
+// DeleteCategory moves a category to the trash, keeping its bookmark
+// assignments so that restoring it brings them back.
 func DeleteCategory(ctx context.Context, categoryID, userID uuid.UUID) error {
-	query := `DELETE FROM categories WHERE id = $1 AND user_id = $2`
+	query := `UPDATE categories SET deleted_at = NOW() WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`
 	result, err := DB.Exec(ctx, query, categoryID, userID)
 	if err != nil {
 		return err
@@ -288,51 +461,96 @@ func DeleteCategory(ctx context.Context, categoryID, userID uuid.UUID) error {
 	return nil
 }
 
-func AssignBookmarkToCategory(ctx context.Context, bookmarkID, categoryID, userID uuid.UUID) error {
-	var bookmarkUserID uuid.UUID
-	err := DB.QueryRow(ctx, `SELECT user_id FROM bookmarks WHERE id = $1`, bookmarkID).Scan(&bookmarkUserID)
+// AssignBookmarkToCategory assigns a bookmark to a category and reports
+// whether the assignment is new.
+func AssignBookmarkToCategory(ctx context.Context, bookmarkID, categoryID, userID uuid.UUID) (bool, error) {
+	if err := checkBookmarksOwner(ctx, DB, []uuid.UUID{bookmarkID}, userID); err != nil {
+		return false, err
+	}
+	if err := checkCategoryOwner(ctx, DB, categoryID, userID); err != nil {
+		return false, err
+	}
+
+	query := `
+		INSERT INTO bookmark_categories (bookmark_id, category_id)
+		VALUES ($1, $2)
+		ON CONFLICT (bookmark_id, category_id) DO NOTHING
+	`
+	result, err := DB.Exec(ctx, query, bookmarkID, categoryID)
 	if err != nil {
-		return fmt.Errorf("bookmark not found")
+		return false, err
 	}
-	if bookmarkUserID != userID {
-		return fmt.Errorf("unauthorized")
+	return result.RowsAffected() > 0, nil
+}
+
+// checkBookmarksOwner verifies that every bookmark exists, is not in the
+// trash and belongs to userID.
+func checkBookmarksOwner(ctx context.Context, q querier, bookmarkIDs []uuid.UUID, userID uuid.UUID) error {
+	rows, err := q.Query(ctx, `SELECT id, user_id FROM bookmarks WHERE id = ANY($1) AND deleted_at IS NULL`, bookmarkIDs)
+	if err != nil {
+		return err
 	}
+	defer rows.Close()
 
+	found := make(map[uuid.UUID]bool, len(bookmarkIDs))
+	for rows.Next() {
+		var id, bookmarkUserID uuid.UUID
+		if err := rows.Scan(&id, &bookmarkUserID); err != nil {
+			return err
+		}
+		if bookmarkUserID != userID {
+			return fmt.Errorf("unauthorized")
+		}
+		found[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range bookmarkIDs {
+		if !found[id] {
+			return fmt.Errorf("bookmark not found")
+		}
+	}
+	return nil
+}
+
+// checkCategoryOwner verifies that the category exists, is not in the trash
+// and belongs to userID.
+func checkCategoryOwner(ctx context.Context, q querier, categoryID, userID uuid.UUID) error {
 	var categoryUserID uuid.UUID
-	err = DB.QueryRow(ctx, `SELECT user_id FROM categories WHERE id = $1`, categoryID).Scan(&categoryUserID)
+	err := q.QueryRow(ctx, `SELECT user_id FROM categories WHERE id = $1 AND deleted_at IS NULL`, categoryID).Scan(&categoryUserID)
 	if err != nil {
 		return fmt.Errorf("category not found")
 	}
 	if categoryUserID != userID {
 		return fmt.Errorf("unauthorized")
 	}
-
-	query := `
-		INSERT INTO bookmark_categories (bookmark_id, category_id)
-		VALUES ($1, $2)
-		ON CONFLICT (bookmark_id, category_id) DO NOTHING
-	`
-	_, err = DB.Exec(ctx, query, bookmarkID, categoryID)
-	return err
+	return nil
 }
 
-func RemoveBookmarkFromCategory(ctx context.Context, bookmarkID, categoryID, userID uuid.UUID) error {
+// RemoveBookmarkFromCategory removes a category assignment and reports
+// whether there was one to remove.
+func RemoveBookmarkFromCategory(ctx context.Context, bookmarkID, categoryID, userID uuid.UUID) (bool, error) {
 	query := `
 		DELETE FROM bookmark_categories
 		WHERE bookmark_id = $1 AND category_id = $2
 		AND bookmark_id IN (SELECT id FROM bookmarks WHERE user_id = $3)
 	`
-	_, err := DB.Exec(ctx, query, bookmarkID, categoryID, userID)
-	return err
+	result, err := DB.Exec(ctx, query, bookmarkID, categoryID, userID)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
 }
 
 func GetUncategorizedBookmarks(ctx context.Context, userID uuid.UUID, limit int) ([]models.Bookmark, error) {
 	query := `
-		SELECT b.id, b.user_id, b.tweet_id, b.tweet_text, b.author_username, 
-		       b.author_display_name, b.tweet_url, b.media_urls, b.bookmarked_at, b.created_at
+		SELECT ` + bookmarkColumns + `
 		FROM bookmarks b
 		LEFT JOIN bookmark_categories bc ON b.id = bc.bookmark_id
-		WHERE b.user_id = $1 AND bc.id IS NULL
+		     AND bc.category_id IN (SELECT id FROM categories WHERE deleted_at IS NULL)
+		WHERE b.user_id = $1 AND b.deleted_at IS NULL AND bc.id IS NULL
 		ORDER BY b.created_at DESC
 		LIMIT $2
 	`
@@ -343,32 +561,17 @@ func GetUncategorizedBookmarks(ctx context.Context, userID uuid.UUID, limit int)
 	}
 	defer rows.Close()
 
-	var bookmarks []models.Bookmark
-	for rows.Next() {
-		var b models.Bookmark
-		err := rows.Scan(&b.ID, &b.UserID, &b.TweetID, &b.TweetText, &b.AuthorUsername,
-			&b.AuthorDisplayName, &b.TweetURL, &b.MediaURLs, &b.BookmarkedAt, &b.CreatedAt)
-		if err != nil {
-			return nil, err
-		}
-		bookmarks = append(bookmarks, b)
-	}
-	return bookmarks, nil
+	return collectBookmarks(rows)
 }
 
 func GetBookmarkByID(ctx context.Context, bookmarkID, userID uuid.UUID) (*models.Bookmark, error) {
 	bookmark := &models.Bookmark{}
 	query := `
-		SELECT id, user_id, tweet_id, tweet_text, author_username, author_display_name, 
-		       tweet_url, media_urls, bookmarked_at, created_at
-		FROM bookmarks
-		WHERE id = $1 AND user_id = $2
+		SELECT ` + bookmarkColumns + `
+		FROM bookmarks b
+		WHERE b.id = $1 AND b.user_id = $2 AND b.deleted_at IS NULL
 	`
-	err := DB.QueryRow(ctx, query, bookmarkID, userID).Scan(
-		&bookmark.ID, &bookmark.UserID, &bookmark.TweetID, &bookmark.TweetText,
-		&bookmark.AuthorUsername, &bookmark.AuthorDisplayName, &bookmark.TweetURL,
-		&bookmark.MediaURLs, &bookmark.BookmarkedAt, &bookmark.CreatedAt,
-	)
+	err := scanBookmark(DB.QueryRow(ctx, query, bookmarkID, userID), bookmark)
 	if err == pgx.ErrNoRows {
 		return nil, fmt.Errorf("bookmark not found")
 	}
@@ -399,55 +602,16 @@ func UpdateUserPreferences(ctx context.Context, userID uuid.UUID, autoCategorize
 
 func GetAllBookmarksByUserID(ctx context.Context, userID uuid.UUID) ([]models.Bookmark, error) {
 	query := `
-		SELECT id, user_id, tweet_id, tweet_text, author_username, author_display_name,
-		       tweet_url, media_urls, bookmarked_at, created_at
-		FROM bookmarks
-		WHERE user_id = $1
-		ORDER BY bookmarked_at DESC
-	`
-	rows, err := DB.Query(ctx, query, userID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var bookmarks []models.Bookmark
-	for rows.Next() {
-		var b models.Bookmark
-		err := rows.Scan(&b.ID, &b.UserID, &b.TweetID, &b.TweetText, &b.AuthorUsername,
-			&b.AuthorDisplayName, &b.TweetURL, &b.MediaURLs, &b.BookmarkedAt, &b.CreatedAt)
-		if err != nil {
-			return nil, err
-		}
-		bookmarks = append(bookmarks, b)
-	}
-	return bookmarks, nil
-}
-
-func GetBookmarksByCategoryID(ctx context.Context, categoryID, userID uuid.UUID) ([]models.Bookmark, error) {
-	query := `
-		SELECT b.id, b.user_id, b.tweet_id, b.tweet_text, b.author_username, b.author_display_name,
-		       b.tweet_url, b.media_urls, b.bookmarked_at, b.created_at
+		SELECT ` + bookmarkColumns + `
 		FROM bookmarks b
-		INNER JOIN bookmark_categories bc ON b.id = bc.bookmark_id
-		WHERE bc.category_id = $1 AND b.user_id = $2
+		WHERE b.user_id = $1 AND b.deleted_at IS NULL
 		ORDER BY b.bookmarked_at DESC
 	`
-	rows, err := DB.Query(ctx, query, categoryID, userID)
+	rows, err := DB.Query(ctx, query, userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var bookmarks []models.Bookmark
-	for rows.Next() {
-		var b models.Bookmark
-		err := rows.Scan(&b.ID, &b.UserID, &b.TweetID, &b.TweetText, &b.AuthorUsername,
-			&b.AuthorDisplayName, &b.TweetURL, &b.MediaURLs, &b.BookmarkedAt, &b.CreatedAt)
-		if err != nil {
-			return nil, err
-		}
-		bookmarks = append(bookmarks, b)
-	}
-	return bookmarks, nil
+	return collectBookmarks(rows)
 }
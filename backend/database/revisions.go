@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"slices"
+	"twitter-bookmarks-api/models"
+
+	"github.com/google/uuid"
+)
+
+// revisionChanges lists the tracked fields that differ between two versions
+// of a bookmark's imported content.
+func revisionChanges(old, new models.Bookmark) []models.FieldChange {
+	var changes []models.FieldChange
+	if old.TweetText != new.TweetText {
+		changes = append(changes, models.FieldChange{Field: "tweet_text", Old: old.TweetText, New: new.TweetText})
+	}
+	if old.AuthorDisplayName != new.AuthorDisplayName {
+		changes = append(changes, models.FieldChange{Field: "author_display_name", Old: old.AuthorDisplayName, New: new.AuthorDisplayName})
+	}
+	if !slices.Equal(old.MediaURLs, new.MediaURLs) {
+		changes = append(changes, models.FieldChange{Field: "media_urls", Old: nonNil(old.MediaURLs), New: nonNil(new.MediaURLs)})
+	}
+	return changes
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// GetBookmarkRevisions returns a bookmark's earlier versions, newest first.
+// Each revision's changes compare it with the version that replaced it.
+func GetBookmarkRevisions(ctx context.Context, bookmarkID, userID uuid.UUID) ([]models.BookmarkRevision, error) {
+	current, err := GetBookmarkByID(ctx, bookmarkID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT id, bookmark_id, COALESCE(tweet_text, ''), COALESCE(author_display_name, ''), media_urls, created_at
+		FROM bookmark_revisions
+		WHERE bookmark_id = $1
+		ORDER BY created_at DESC
+	`
+	rows, err := DB.Query(ctx, query, bookmarkID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []models.BookmarkRevision{}
+	next := *current
+	for rows.Next() {
+		var r models.BookmarkRevision
+		err := rows.Scan(&r.ID, &r.BookmarkID, &r.TweetText, &r.AuthorDisplayName, &r.MediaURLs, &r.RevisedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		version := models.Bookmark{TweetText: r.TweetText, AuthorDisplayName: r.AuthorDisplayName, MediaURLs: r.MediaURLs}
+		r.Changes = revisionChanges(version, next)
+		if r.Changes == nil {
+			r.Changes = []models.FieldChange{}
+		}
+		r.MediaURLs = nonNil(r.MediaURLs)
+		revisions = append(revisions, r)
+		next = version
+	}
+	return revisions, rows.Err()
+}
@@ -0,0 +1,172 @@
+package database
+
+import (
+	"context"
+	"time"
+	"twitter-bookmarks-api/models"
+
+	"github.com/google/uuid"
+)
+
+// ClaimBookmarksForStatusCheck returns up to limit bookmarks of all users
+// whose status was never checked or was last attempted more than maxAge ago,
+// least recently attempted first, and stamps them as attempted now. A
+// bookmark whose check fails therefore waits maxAge before it is tried again
+// instead of being picked first on every pass, and concurrent checkers skip
+// the bookmarks another one claimed.
+func ClaimBookmarksForStatusCheck(ctx context.Context, maxAge time.Duration, limit int) ([]models.Bookmark, error) {
+	query := `
+		WITH due AS (
+			SELECT id FROM bookmarks
+			WHERE deleted_at IS NULL
+			  AND (COALESCE(status_attempted_at, status_checked_at) IS NULL
+			       OR COALESCE(status_attempted_at, status_checked_at) < NOW() - make_interval(secs => $1))
+			ORDER BY COALESCE(status_attempted_at, status_checked_at) ASC NULLS FIRST
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE bookmarks b SET status_attempted_at = NOW()
+		FROM due
+		WHERE b.id = due.id
+		RETURNING ` + bookmarkColumns + `
+	`
+	rows, err := DB.Query(ctx, query, maxAge.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return collectBookmarks(rows)
+}
+
+// UpdateBookmarkStatus records the outcome of a status check. The status the
+// bookmark had before this check is kept in previous_status so that the dead
+// bookmarks report can tell which bookmarks died on their latest check.
+func UpdateBookmarkStatus(ctx context.Context, bookmarkID uuid.UUID, status string, links []models.BookmarkLink) error {
+	tx, err := DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		UPDATE bookmarks
+		SET previous_status = status, status = $1, status_checked_at = NOW()
+		WHERE id = $2
+	`
+	if _, err := tx.Exec(ctx, query, status, bookmarkID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM bookmark_links WHERE bookmark_id = $1`, bookmarkID); err != nil {
+		return err
+	}
+
+	for _, link := range links {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO bookmark_links (bookmark_id, url, expanded_url, status, checked_at)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (bookmark_id, url) DO NOTHING
+		`, bookmarkID, link.URL, link.ExpandedURL, link.Status, link.CheckedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func GetBookmarkLinks(ctx context.Context, bookmarkID uuid.UUID) ([]models.BookmarkLink, error) {
+	query := `
+		SELECT url, COALESCE(expanded_url, ''), status, checked_at
+		FROM bookmark_links
+		WHERE bookmark_id = $1
+		ORDER BY url
+	`
+	rows, err := DB.Query(ctx, query, bookmarkID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []models.BookmarkLink
+	for rows.Next() {
+		var l models.BookmarkLink
+		if err := rows.Scan(&l.URL, &l.ExpandedURL, &l.Status, &l.CheckedAt); err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
+
+// GetDeadBookmarks returns the user's bookmarks whose latest check found them
+// deleted, protected or 404 when the check before that did not.
+func GetDeadBookmarks(ctx context.Context, userID uuid.UUID) ([]models.DeadBookmark, error) {
+	query := `
+		SELECT ` + bookmarkColumns + `, COALESCE(b.previous_status, '')
+		FROM bookmarks b
+		WHERE b.user_id = $1 AND b.deleted_at IS NULL
+		  AND b.status IN ($2, $3, $4)
+		  AND COALESCE(b.previous_status, '') NOT IN ($2, $3, $4)
+		ORDER BY b.status_checked_at DESC
+	`
+	rows, err := DB.Query(ctx, query, userID,
+		models.BookmarkStatusDeleted, models.BookmarkStatusProtected, models.BookmarkStatusNotFound)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var dead []models.DeadBookmark
+	for rows.Next() {
+		var d models.DeadBookmark
+		if err := scanBookmark(rows, &d.Bookmark, &d.PreviousStatus); err != nil {
+			return nil, err
+		}
+		dead = append(dead, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	ids := make([]uuid.UUID, len(dead))
+	for i := range dead {
+		ids[i] = dead[i].ID
+	}
+	links, err := getLinksOfBookmarks(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range dead {
+		dead[i].Links = links[dead[i].ID]
+	}
+	return dead, nil
+}
+
+// getLinksOfBookmarks returns the links of several bookmarks by bookmark id.
+func getLinksOfBookmarks(ctx context.Context, bookmarkIDs []uuid.UUID) (map[uuid.UUID][]models.BookmarkLink, error) {
+	query := `
+		SELECT bookmark_id, url, COALESCE(expanded_url, ''), status, checked_at
+		FROM bookmark_links
+		WHERE bookmark_id = ANY($1)
+		ORDER BY bookmark_id, url
+	`
+	rows, err := DB.Query(ctx, query, bookmarkIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := make(map[uuid.UUID][]models.BookmarkLink)
+	for rows.Next() {
+		var bookmarkID uuid.UUID
+		var l models.BookmarkLink
+		if err := rows.Scan(&bookmarkID, &l.URL, &l.ExpandedURL, &l.Status, &l.CheckedAt); err != nil {
+			return nil, err
+		}
+		links[bookmarkID] = append(links[bookmarkID], l)
+	}
+	return links, rows.Err()
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"twitter-bookmarks-api/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// RecordSyncSession adds a finished import to the user's sync history.
+func RecordSyncSession(ctx context.Context, userID uuid.UUID, session *models.SyncSession) error {
+	query := `
+		INSERT INTO sync_sessions (user_id, source, item_count, imported_count, updated_count, duplicate_count,
+		                           failed_count, complete, head_tweet_ids, operation_id, started_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, finished_at
+	`
+	headTweetIDs := session.HeadTweetIDs
+	if headTweetIDs == nil {
+		headTweetIDs = []string{}
+	}
+	return DB.QueryRow(ctx, query, userID, session.Source, session.ItemCount, session.ImportedCount,
+		session.UpdatedCount, session.DuplicateCount, session.FailedCount, session.Complete, headTweetIDs,
+		session.OperationID, session.StartedAt).Scan(&session.ID, &session.FinishedAt)
+}
+
+// GetSyncState returns the first tweets and finish time of the user's last
+// complete extension sync, along with their most recent sync sessions of any
+// source.
+func GetSyncState(ctx context.Context, userID uuid.UUID, historyLimit int) (*models.SyncState, error) {
+	state := &models.SyncState{NewestTweetIDs: []string{}, Sessions: []models.SyncSession{}}
+
+	rows, err := DB.Query(ctx, `
+		SELECT id, source, item_count, imported_count, updated_count, duplicate_count, failed_count,
+		       complete, head_tweet_ids, operation_id, started_at, finished_at
+		FROM sync_sessions
+		WHERE user_id = $1
+		ORDER BY finished_at DESC
+		LIMIT $2
+	`, userID, historyLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s models.SyncSession
+		err := rows.Scan(&s.ID, &s.Source, &s.ItemCount, &s.ImportedCount, &s.UpdatedCount, &s.DuplicateCount,
+			&s.FailedCount, &s.Complete, &s.HeadTweetIDs, &s.OperationID, &s.StartedAt, &s.FinishedAt)
+		if err != nil {
+			return nil, err
+		}
+		state.Sessions = append(state.Sessions, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	// The watermark only comes from complete syncs: tweets after the head of
+	// an interrupted sync may never have been saved.
+	var last models.SyncSession
+	err = DB.QueryRow(ctx, `
+		SELECT head_tweet_ids, finished_at
+		FROM sync_sessions
+		WHERE user_id = $1 AND source = $2 AND complete
+		ORDER BY finished_at DESC
+		LIMIT 1
+	`, userID, models.ImportSourceExtension).Scan(&last.HeadTweetIDs, &last.FinishedAt)
+	if err == pgx.ErrNoRows {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state.NewestTweetIDs = last.HeadTweetIDs
+	state.LastSyncedAt = &last.FinishedAt
+	return state, nil
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"twitter-bookmarks-api/models"
+
+	"github.com/google/uuid"
+)
+
+// GetTrashedBookmarks returns the user's soft-deleted bookmarks, most recently
+// deleted first, with the categories they will be restored into.
+func GetTrashedBookmarks(ctx context.Context, userID uuid.UUID) ([]models.Bookmark, error) {
+	query := `
+		SELECT ` + bookmarkColumns + `
+		FROM bookmarks b
+		WHERE b.user_id = $1 AND b.deleted_at IS NOT NULL
+		ORDER BY b.deleted_at DESC
+	`
+	rows, err := DB.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	bookmarks, err := collectBookmarks(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range bookmarks {
+		categories, _ := GetCategoriesByBookmarkID(ctx, bookmarks[i].ID)
+		bookmarks[i].Categories = categories
+	}
+	return bookmarks, nil
+}
+
+// GetTrashedCategories returns the user's soft-deleted categories, most
+// recently deleted first, with the number of assignments a restore brings back.
+func GetTrashedCategories(ctx context.Context, userID uuid.UUID) ([]models.Category, error) {
+	query := `
+		SELECT c.id, c.user_id, c.name, c.color, c.icon, c.created_at, c.deleted_at,
+		       COUNT(bc.bookmark_id) as count
+		FROM categories c
+		LEFT JOIN bookmark_categories bc ON c.id = bc.category_id
+		WHERE c.user_id = $1 AND c.deleted_at IS NOT NULL
+		GROUP BY c.id
+		ORDER BY c.deleted_at DESC
+	`
+	rows, err := DB.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []models.Category
+	for rows.Next() {
+		var c models.Category
+		err := rows.Scan(&c.ID, &c.UserID, &c.Name, &c.Color, &c.Icon, &c.CreatedAt, &c.DeletedAt, &c.Count)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
+
+// RestoreFromTrash restores the trashed bookmark or category with the given
+// id and reports which kind it was. Category assignments are never removed
+// by a soft delete, so they come back along with the item.
+func RestoreFromTrash(ctx context.Context, id, userID uuid.UUID) (string, error) {
+	result, err := DB.Exec(ctx,
+		`UPDATE bookmarks SET deleted_at = NULL WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL`,
+		id, userID)
+	if err != nil {
+		return "", err
+	}
+	if result.RowsAffected() > 0 {
+		return "bookmark", nil
+	}
+
+	result, err = DB.Exec(ctx,
+		`UPDATE categories SET deleted_at = NULL WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL`,
+		id, userID)
+	if err != nil {
+		return "", err
+	}
+	if result.RowsAffected() > 0 {
+		return "category", nil
+	}
+
+	return "", fmt.Errorf("item not found in trash")
+}
+
+// PurgeTrash permanently deletes bookmarks and categories that were moved to
+// the trash before deletedBefore. Their category assignments are removed by
+// the ON DELETE CASCADE foreign keys.
+func PurgeTrash(ctx context.Context, deletedBefore time.Time) (int64, int64, error) {
+	bookmarks, err := DB.Exec(ctx, `DELETE FROM bookmarks WHERE deleted_at < $1`, deletedBefore)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	categories, err := DB.Exec(ctx, `DELETE FROM categories WHERE deleted_at < $1`, deletedBefore)
+	if err != nil {
+		return bookmarks.RowsAffected(), 0, err
+	}
+
+	return bookmarks.RowsAffected(), categories.RowsAffected(), nil
+}
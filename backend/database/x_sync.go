@@ -0,0 +1,219 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+	"twitter-bookmarks-api/auth"
+	"twitter-bookmarks-api/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrXNotConnected = errors.New("X account not connected")
+
+const xSyncColumns = `
+	user_id, status, COALESCE(cursor, ''), next_sync_at, last_synced_at, last_run_at,
+	imported_count, updated_count, failed_count, operation_id, COALESCE(error, '')
+`
+
+func scanXSync(row pgx.Row, sync *models.XSync) error {
+	err := row.Scan(
+		&sync.UserID, &sync.Status, &sync.Cursor, &sync.NextSyncAt, &sync.LastSyncedAt, &sync.LastRunAt,
+		&sync.ImportedCount, &sync.UpdatedCount, &sync.FailedCount, &sync.OperationID, &sync.Error,
+	)
+	sync.Resuming = sync.Cursor != ""
+	return err
+}
+
+// SaveXToken stores the token a user granted when signing in, encrypted with
+// auth.SealToken, and makes their bookmark sync due. A sync that failed, for
+// example because the previous token was revoked, starts over.
+func SaveXToken(ctx context.Context, userID uuid.UUID, token models.XToken) error {
+	tx, err := DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := saveXToken(ctx, tx, userID, token); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO x_syncs (user_id) VALUES ($1)
+		ON CONFLICT (user_id) DO UPDATE
+		SET status = $2, next_sync_at = NOW(), error = NULL
+		WHERE x_syncs.status = $3
+	`, userID, models.XSyncStatusIdle, models.XSyncStatusFailed)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// UpdateXToken stores a token the sync refreshed, leaving the sync itself
+// alone.
+func UpdateXToken(ctx context.Context, userID uuid.UUID, token models.XToken) error {
+	return saveXToken(ctx, DB, userID, token)
+}
+
+func saveXToken(ctx context.Context, q querier, userID uuid.UUID, token models.XToken) error {
+	accessToken, err := auth.SealToken(token.AccessToken)
+	if err != nil {
+		return err
+	}
+	refreshToken, err := auth.SealToken(token.RefreshToken)
+	if err != nil {
+		return err
+	}
+
+	var expiry *time.Time
+	if !token.Expiry.IsZero() {
+		expiry = &token.Expiry
+	}
+	_, err = q.Exec(ctx, `
+		INSERT INTO x_tokens (user_id, access_token, refresh_token, token_type, expires_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5)
+		ON CONFLICT (user_id) DO UPDATE
+		SET access_token = EXCLUDED.access_token,
+		    refresh_token = COALESCE(EXCLUDED.refresh_token, x_tokens.refresh_token),
+		    token_type = EXCLUDED.token_type, expires_at = EXCLUDED.expires_at, updated_at = NOW()
+	`, userID, accessToken, refreshToken, token.TokenType, expiry)
+	return err
+}
+
+// GetXToken returns the stored token for a user, decrypted.
+func GetXToken(ctx context.Context, userID uuid.UUID) (*models.XToken, error) {
+	token := &models.XToken{}
+	var accessToken string
+	var refreshToken, tokenType *string
+	var expiry *time.Time
+	err := DB.QueryRow(ctx, `
+		SELECT access_token, refresh_token, token_type, expires_at FROM x_tokens WHERE user_id = $1
+	`, userID).Scan(&accessToken, &refreshToken, &tokenType, &expiry)
+	if err == pgx.ErrNoRows {
+		return nil, ErrXNotConnected
+	}
+	if err != nil {
+		return nil, err
+	}
+	if token.AccessToken, err = auth.OpenToken(accessToken); err != nil {
+		return nil, err
+	}
+	if refreshToken != nil {
+		if token.RefreshToken, err = auth.OpenToken(*refreshToken); err != nil {
+			return nil, err
+		}
+	}
+	if tokenType != nil {
+		token.TokenType = *tokenType
+	}
+	if expiry != nil {
+		token.Expiry = *expiry
+	}
+	return token, nil
+}
+
+func GetXSync(ctx context.Context, userID uuid.UUID) (*models.XSync, error) {
+	sync := &models.XSync{}
+	err := scanXSync(DB.QueryRow(ctx, `SELECT `+xSyncColumns+` FROM x_syncs WHERE user_id = $1`, userID), sync)
+	if err == pgx.ErrNoRows {
+		return nil, ErrXNotConnected
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sync, nil
+}
+
+// RequestXSync makes a user's sync due now. A sync waiting for a rate limit
+// to reset keeps waiting.
+func RequestXSync(ctx context.Context, userID uuid.UUID) (*models.XSync, error) {
+	sync := &models.XSync{}
+	query := `
+		UPDATE x_syncs
+		SET next_sync_at = CASE WHEN status = $2 THEN GREATEST(next_sync_at, NOW()) ELSE NOW() END
+		WHERE user_id = $1
+		RETURNING ` + xSyncColumns
+	err := scanXSync(DB.QueryRow(ctx, query, userID, models.XSyncStatusRateLimited), sync)
+	if err == pgx.ErrNoRows {
+		return nil, ErrXNotConnected
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sync, nil
+}
+
+// ClaimDueXSync marks the most overdue sync as running and returns it, or nil
+// when none is due. SKIP LOCKED keeps concurrent workers from claiming the
+// same sync. The worker must keep the sync's heartbeat fresh with TouchXSync
+// while it runs.
+func ClaimDueXSync(ctx context.Context) (*models.XSync, error) {
+	sync := &models.XSync{}
+	query := `
+		UPDATE x_syncs
+		SET status = $1, last_run_at = NOW(), heartbeat_at = NOW()
+		WHERE user_id = (
+			SELECT s.user_id FROM x_syncs s
+			JOIN x_tokens t ON t.user_id = s.user_id
+			WHERE s.status <> $1 AND s.next_sync_at <= NOW()
+			ORDER BY s.next_sync_at
+			LIMIT 1
+			FOR UPDATE OF s SKIP LOCKED
+		)
+		RETURNING ` + xSyncColumns
+	err := scanXSync(DB.QueryRow(ctx, query, models.XSyncStatusRunning), sync)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sync, nil
+}
+
+// SaveXSyncCursor records the page a running sync continues from, so that a
+// restart resumes there.
+func SaveXSyncCursor(ctx context.Context, userID uuid.UUID, cursor string) error {
+	_, err := DB.Exec(ctx, `UPDATE x_syncs SET cursor = NULLIF($2, ''), heartbeat_at = NOW() WHERE user_id = $1`, userID, cursor)
+	return err
+}
+
+// TouchXSync refreshes the heartbeat of a running sync, showing that its
+// worker is still alive.
+func TouchXSync(ctx context.Context, userID uuid.UUID) error {
+	_, err := DB.Exec(ctx, `UPDATE x_syncs SET heartbeat_at = NOW() WHERE user_id = $1 AND status = $2`,
+		userID, models.XSyncStatusRunning)
+	return err
+}
+
+// FinishXSync records the outcome of a sync run.
+func FinishXSync(ctx context.Context, sync *models.XSync) error {
+	query := `
+		UPDATE x_syncs
+		SET status = $2, cursor = NULLIF($3, ''), next_sync_at = $4, last_synced_at = $5,
+		    imported_count = $6, updated_count = $7, failed_count = $8, operation_id = $9, error = NULLIF($10, '')
+		WHERE user_id = $1
+	`
+	_, err := DB.Exec(ctx, query, sync.UserID, sync.Status, sync.Cursor, sync.NextSyncAt, sync.LastSyncedAt,
+		sync.ImportedCount, sync.UpdatedCount, sync.FailedCount, sync.OperationID, sync.Error)
+	return err
+}
+
+// ResetAbandonedXSyncs makes running syncs whose heartbeat is older than
+// timeout due again: their worker stopped without finishing them. Syncs
+// other workers are running keep a fresh heartbeat and are left alone.
+// Reset syncs resume from their saved cursor.
+func ResetAbandonedXSyncs(ctx context.Context, timeout time.Duration) (int64, error) {
+	result, err := DB.Exec(ctx, `
+		UPDATE x_syncs SET status = $1, next_sync_at = NOW()
+		WHERE status = $2 AND COALESCE(heartbeat_at, last_run_at) < NOW() - make_interval(secs => $3)
+	`, models.XSyncStatusIdle, models.XSyncStatusRunning, timeout.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,90 @@
+package exporters
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"twitter-bookmarks-api/models"
+)
+
+// categorySeparator joins a bookmark's categories into one cell.
+const categorySeparator = "; "
+
+// DefaultColumns are the columns of a tabular export when none are picked.
+var DefaultColumns = []string{
+	"tweet_id", "tweet_url", "author_username", "author_display_name", "tweet_text", "categories", "bookmarked_at",
+}
+
+// columns read a field of a bookmark for tabular exports. Values are either
+// strings or times; nil stands for an unset time.
+var columns = map[string]func(b *models.Bookmark) interface{}{
+	"id":                  func(b *models.Bookmark) interface{} { return b.ID.String() },
+	"tweet_id":            func(b *models.Bookmark) interface{} { return b.TweetID },
+	"tweet_url":           func(b *models.Bookmark) interface{} { return b.TweetURL },
+	"tweet_text":          func(b *models.Bookmark) interface{} { return b.TweetText },
+	"author_username":     func(b *models.Bookmark) interface{} { return b.AuthorUsername },
+	"author_display_name": func(b *models.Bookmark) interface{} { return b.AuthorDisplayName },
+	"title":               func(b *models.Bookmark) interface{} { return b.Title },
+	"notes":               func(b *models.Bookmark) interface{} { return b.Notes },
+	"categories":          func(b *models.Bookmark) interface{} { return categoryNames(b) },
+	"media_urls":          func(b *models.Bookmark) interface{} { return strings.Join(b.MediaURLs, " ") },
+	"lang":                func(b *models.Bookmark) interface{} { return b.Lang },
+	"status":              func(b *models.Bookmark) interface{} { return b.Status },
+	"bookmarked_at":       func(b *models.Bookmark) interface{} { return b.BookmarkedAt },
+	"created_at":          func(b *models.Bookmark) interface{} { return b.CreatedAt },
+	"updated_at":          func(b *models.Bookmark) interface{} { return b.UpdatedAt },
+	"archived_at":         func(b *models.Bookmark) interface{} { return optionalTime(b.ArchivedAt) },
+	"read_at":             func(b *models.Bookmark) interface{} { return optionalTime(b.ReadAt) },
+}
+
+func categoryNames(b *models.Bookmark) string {
+	names := make([]string, len(b.Categories))
+	for i, cat := range b.Categories {
+		names[i] = cat.Name
+	}
+	return strings.Join(names, categorySeparator)
+}
+
+func optionalTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return *t
+}
+
+// ColumnNames lists the columns tabular exports can include.
+func ColumnNames() []string {
+	names := make([]string, 0, len(columns))
+	for name := range columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseColumns reads a comma-separated list of columns, returning
+// DefaultColumns for an empty list.
+func ParseColumns(value string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return DefaultColumns, nil
+	}
+	var picked []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("unknown column %q, expected one of %s", name, strings.Join(ColumnNames(), ", "))
+		}
+		picked = append(picked, name)
+	}
+	return picked, nil
+}
+
+// row returns the values of the given columns for b.
+func row(b *models.Bookmark, names []string) []interface{} {
+	values := make([]interface{}, len(names))
+	for i, name := range names {
+		values[i] = columns[name](b)
+	}
+	return values
+}
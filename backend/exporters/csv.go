@@ -0,0 +1,77 @@
+package exporters
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+	"time"
+	"twitter-bookmarks-api/models"
+)
+
+// utf8BOM makes Excel read the file as UTF-8 instead of the system code page.
+const utf8BOM = "\ufeff"
+
+// csvExporter writes an RFC 4180 file with a header row and one row per
+// bookmark, with times in RFC 3339.
+type csvExporter struct {
+	out     io.Writer
+	w       *csv.Writer
+	columns []string
+	started bool
+}
+
+func newCSVExporter(w io.Writer, opts Options) Exporter {
+	cw := csv.NewWriter(w)
+	cw.UseCRLF = true
+	return &csvExporter{out: w, w: cw, columns: opts.Columns}
+}
+
+func (e *csvExporter) begin() error {
+	if e.started {
+		return nil
+	}
+	e.started = true
+	if _, err := io.WriteString(e.out, utf8BOM); err != nil {
+		return err
+	}
+	return e.w.Write(e.columns)
+}
+
+func (e *csvExporter) Write(b *models.Bookmark) error {
+	if err := e.begin(); err != nil {
+		return err
+	}
+	values := row(b, e.columns)
+	record := make([]string, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case string:
+			record[i] = neutralizeFormula(v)
+		case time.Time:
+			record[i] = v.UTC().Format(time.RFC3339)
+		}
+	}
+	return e.w.Write(record)
+}
+
+func (e *csvExporter) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvExporter) Close() error {
+	if err := e.begin(); err != nil {
+		return err
+	}
+	return e.Flush()
+}
+
+// neutralizeFormula keeps spreadsheets from evaluating text that starts
+// like a formula, such as a tweet beginning with "=" or "@", by prefixing
+// it with an apostrophe.
+func neutralizeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,88 @@
+package exporters
+
+import (
+	"bytes"
+	"testing"
+	"time"
+	"twitter-bookmarks-api/models"
+)
+
+func TestCSVExporter(t *testing.T) {
+	bookmarkedAt := time.Date(2024, 5, 1, 12, 30, 0, 0, time.FixedZone("CEST", 2*60*60))
+	archivedAt := time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		columns   []string
+		bookmarks []models.Bookmark
+		want      string
+	}{
+		{
+			name:    "empty export has the header",
+			columns: []string{"tweet_id", "tweet_text"},
+			want:    "\ufefftweet_id,tweet_text\r\n",
+		},
+		{
+			name:    "quoting and times",
+			columns: []string{"tweet_id", "tweet_text", "categories", "bookmarked_at", "archived_at"},
+			bookmarks: []models.Bookmark{{
+				TweetID:      "20",
+				TweetText:    "hello, \"world\"\nsecond line",
+				Categories:   []models.Category{{Name: "Go"}, {Name: "News"}},
+				BookmarkedAt: bookmarkedAt,
+				ArchivedAt:   &archivedAt,
+			}},
+			want: "\ufefftweet_id,tweet_text,categories,bookmarked_at,archived_at\r\n" +
+				"20,\"hello, \"\"world\"\"\r\nsecond line\",Go; News,2024-05-01T10:30:00Z,2024-06-01T08:00:00Z\r\n",
+		},
+		{
+			name:    "unset times are empty",
+			columns: []string{"tweet_id", "read_at"},
+			bookmarks: []models.Bookmark{
+				{TweetID: "21"},
+			},
+			want: "\ufefftweet_id,read_at\r\n21,\r\n",
+		},
+		{
+			name:    "formulas are neutralized",
+			columns: []string{"tweet_text"},
+			bookmarks: []models.Bookmark{
+				{TweetText: "=HYPERLINK(\"http://evil\")"},
+				{TweetText: "+1"},
+				{TweetText: "-1"},
+				{TweetText: "@jack"},
+				{TweetText: "a = b"},
+			},
+			want: "\ufefftweet_text\r\n" +
+				"\"'=HYPERLINK(\"\"http://evil\"\")\"\r\n'+1\r\n'-1\r\n'@jack\r\na = b\r\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			e := newCSVExporter(&buf, Options{Columns: tt.columns})
+			for i := range tt.bookmarks {
+				if err := e.Write(&tt.bookmarks[i]); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+			}
+			if err := e.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("export = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSVExporterWritesNothingBeforeFirstRow(t *testing.T) {
+	var buf bytes.Buffer
+	e := newCSVExporter(&buf, Options{Columns: DefaultColumns})
+	if err := e.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Flush() before any row wrote %q", buf.String())
+	}
+}
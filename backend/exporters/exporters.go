@@ -0,0 +1,66 @@
+// Package exporters writes bookmarks in download formats. An Exporter is
+// handed bookmarks one at a time as they are read from the database, so an
+// export never holds the whole library in memory.
+package exporters
+
+import (
+	"io"
+	"sort"
+	"twitter-bookmarks-api/models"
+)
+
+// Exporter writes one export. Nothing is written to the underlying writer
+// before the first call to Write or Close, so a caller can still report an
+// error instead of starting the download.
+type Exporter interface {
+	// Write adds a bookmark to the export.
+	Write(b *models.Bookmark) error
+	// Flush writes out anything buffered so far.
+	Flush() error
+	// Close finishes the export. It must be called even if no bookmarks
+	// were written.
+	Close() error
+}
+
+// Options configure an export.
+type Options struct {
+	// Category is the category being exported, or nil for the whole
+	// library.
+	Category *models.Category
+	// Columns are the fields written by tabular formats, in order.
+	Columns []string
+}
+
+// Format is a download format.
+type Format struct {
+	ContentType string
+	Extension   string
+	// ByCategory formats expect bookmarks grouped by category, as
+	// database.StreamBookmarksByCategory passes them.
+	ByCategory bool
+	New        func(w io.Writer, opts Options) Exporter
+}
+
+var formats = map[string]Format{
+	"json":     {ContentType: "application/json", Extension: "json", New: newJSONExporter},
+	"csv":      {ContentType: "text/csv; charset=utf-8", Extension: "csv", New: newCSVExporter},
+	"xlsx":     {ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", Extension: "xlsx", New: newXLSXExporter},
+	"markdown": {ContentType: "application/zip", Extension: "zip", New: newMarkdownExporter},
+	"html":     {ContentType: "text/html; charset=utf-8", Extension: "html", ByCategory: true, New: newHTMLExporter},
+}
+
+// Get returns the format named name.
+func Get(name string) (Format, bool) {
+	format, ok := formats[name]
+	return format, ok
+}
+
+// Formats lists the supported formats.
+func Formats() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,130 @@
+package exporters
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"twitter-bookmarks-api/models"
+
+	"github.com/google/uuid"
+)
+
+// maxLinkTitleLen is how much of a tweet titles its link when the bookmark
+// has no title of its own.
+const maxLinkTitleLen = 100
+
+const netscapeHeader = `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file.
+     It will be read and overwritten.
+     DO NOT EDIT! -->
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+`
+
+// htmlExporter writes the Netscape bookmark file format browsers import,
+// with a folder per category and bookmarks without a category at the top
+// level. It expects bookmarks grouped by category, as
+// database.StreamBookmarksByCategory passes them. The tweet text goes in
+// each link's description.
+type htmlExporter struct {
+	w       io.Writer
+	started bool
+	// folder is the category whose folder is open, or uuid.Nil.
+	folder uuid.UUID
+}
+
+func newHTMLExporter(w io.Writer, opts Options) Exporter {
+	return &htmlExporter{w: w}
+}
+
+func (e *htmlExporter) begin() error {
+	if e.started {
+		return nil
+	}
+	e.started = true
+	_, err := io.WriteString(e.w, netscapeHeader)
+	return err
+}
+
+func (e *htmlExporter) closeFolder() error {
+	if e.folder == uuid.Nil {
+		return nil
+	}
+	e.folder = uuid.Nil
+	_, err := io.WriteString(e.w, "    </DL><p>\n")
+	return err
+}
+
+func (e *htmlExporter) Write(b *models.Bookmark) error {
+	if err := e.begin(); err != nil {
+		return err
+	}
+
+	indent := "    "
+	if len(b.Categories) == 0 {
+		if err := e.closeFolder(); err != nil {
+			return err
+		}
+	} else {
+		category := b.Categories[0]
+		if category.ID != e.folder {
+			if err := e.closeFolder(); err != nil {
+				return err
+			}
+			addDate := ""
+			if !category.CreatedAt.IsZero() {
+				addDate = fmt.Sprintf(" ADD_DATE=\"%d\"", category.CreatedAt.Unix())
+			}
+			_, err := fmt.Fprintf(e.w, "    <DT><H3%s>%s</H3>\n    <DL><p>\n", addDate, html.EscapeString(category.Name))
+			if err != nil {
+				return err
+			}
+			e.folder = category.ID
+		}
+		indent += "    "
+	}
+
+	_, err := fmt.Fprintf(e.w, "%s<DT><A HREF=\"%s\" ADD_DATE=\"%d\">%s</A>\n",
+		indent, html.EscapeString(b.TweetURL), b.BookmarkedAt.Unix(), html.EscapeString(linkTitle(b)))
+	if err != nil {
+		return err
+	}
+	if b.TweetText != "" {
+		_, err = fmt.Fprintf(e.w, "%s<DD>%s\n", indent, html.EscapeString(b.TweetText))
+	}
+	return err
+}
+
+func (e *htmlExporter) Flush() error {
+	return nil
+}
+
+func (e *htmlExporter) Close() error {
+	if err := e.begin(); err != nil {
+		return err
+	}
+	if err := e.closeFolder(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(e.w, "</DL><p>\n")
+	return err
+}
+
+// linkTitle is the bookmark's title, or its author and the start of its
+// text.
+func linkTitle(b *models.Bookmark) string {
+	if b.Title != "" {
+		return b.Title
+	}
+	text := strings.Join(strings.Fields(b.TweetText), " ")
+	if runes := []rune(text); len(runes) > maxLinkTitleLen {
+		text = string(runes[:maxLinkTitleLen]) + "…"
+	}
+	if text == "" {
+		return "@" + b.AuthorUsername
+	}
+	return "@" + b.AuthorUsername + ": " + text
+}
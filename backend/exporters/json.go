@@ -0,0 +1,69 @@
+package exporters
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"twitter-bookmarks-api/models"
+)
+
+// jsonExporter writes {"category": ..., "bookmarks": [...]}, leaving out
+// category for whole-library exports.
+type jsonExporter struct {
+	w        io.Writer
+	category *models.Category
+	written  int
+}
+
+func newJSONExporter(w io.Writer, opts Options) Exporter {
+	return &jsonExporter{w: w, category: opts.Category}
+}
+
+func (e *jsonExporter) begin() error {
+	if e.category == nil {
+		_, err := io.WriteString(e.w, `{"bookmarks":[`)
+		return err
+	}
+	head, err := json.Marshal(e.category)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(e.w, `{"category":%s,"bookmarks":[`, head)
+	return err
+}
+
+func (e *jsonExporter) Write(b *models.Bookmark) error {
+	sep := ","
+	if e.written == 0 {
+		if err := e.begin(); err != nil {
+			return err
+		}
+		sep = ""
+	}
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(e.w, sep); err != nil {
+		return err
+	}
+	if _, err := e.w.Write(data); err != nil {
+		return err
+	}
+	e.written++
+	return nil
+}
+
+func (e *jsonExporter) Flush() error {
+	return nil
+}
+
+func (e *jsonExporter) Close() error {
+	if e.written == 0 {
+		if err := e.begin(); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(e.w, "]}")
+	return err
+}
@@ -0,0 +1,286 @@
+package exporters
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"twitter-bookmarks-api/models"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// uncategorizedFolder holds bookmarks without a category.
+	uncategorizedFolder = "Uncategorized"
+	// maxNoteTitleLen is how much of a tweet names its note.
+	maxNoteTitleLen = 60
+	// markdownDateLayout is a date and time Obsidian reads as a property.
+	markdownDateLayout = "2006-01-02T15:04:05"
+)
+
+var hashtagPattern = regexp.MustCompile(`(?:^|\s)#([\pL\pN_]*\pL[\pL\pN_]*)`)
+
+// markdownExporter writes an Obsidian vault as a zip: one note per bookmark,
+// in a folder per category, and an index note per folder linking to the
+// notes of its category. A bookmark with several categories is filed under
+// the first and listed in the index of each. Notes are streamed into the zip
+// as they come; only note names are kept for the index notes, which are
+// written last.
+type markdownExporter struct {
+	zip      *zip.Writer
+	category *models.Category
+	// folders maps category IDs, and uuid.Nil for uncategorized bookmarks,
+	// to their folder.
+	folders map[uuid.UUID]*markdownFolder
+	// order lists the folders in the order they were created.
+	order []*markdownFolder
+	// taken holds the folder names in use, lowercased, since vaults often
+	// live on case-insensitive file systems.
+	taken map[string]bool
+}
+
+type markdownFolder struct {
+	name     string
+	category string
+	notes    []string
+}
+
+func newMarkdownExporter(w io.Writer, opts Options) Exporter {
+	return &markdownExporter{
+		zip:      zip.NewWriter(w),
+		category: opts.Category,
+		folders:  make(map[uuid.UUID]*markdownFolder),
+		taken:    make(map[string]bool),
+	}
+}
+
+func (e *markdownExporter) folder(id uuid.UUID, category string) *markdownFolder {
+	if f, ok := e.folders[id]; ok {
+		return f
+	}
+	base := fileName(category)
+	if base == "" {
+		base = "Untitled"
+	}
+	name := base
+	for n := 2; e.taken[strings.ToLower(name)]; n++ {
+		name = fmt.Sprintf("%s (%d)", base, n)
+	}
+	e.taken[strings.ToLower(name)] = true
+
+	f := &markdownFolder{name: name, category: category}
+	e.folders[id] = f
+	e.order = append(e.order, f)
+	return f
+}
+
+func (e *markdownExporter) Write(b *models.Bookmark) error {
+	// A category export files everything under that category.
+	var folders []*markdownFolder
+	switch {
+	case e.category != nil:
+		folders = []*markdownFolder{e.folder(e.category.ID, e.category.Name)}
+	case len(b.Categories) == 0:
+		folders = []*markdownFolder{e.folder(uuid.Nil, uncategorizedFolder)}
+	default:
+		for _, cat := range b.Categories {
+			folders = append(folders, e.folder(cat.ID, cat.Name))
+		}
+	}
+	home := folders[0]
+
+	name := noteName(b)
+	f, err := e.zip.Create(home.name + "/" + name + ".md")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, bookmarkNote(b)); err != nil {
+		return err
+	}
+	for _, folder := range folders {
+		folder.notes = append(folder.notes, name)
+	}
+	return nil
+}
+
+func (e *markdownExporter) Flush() error {
+	return e.zip.Flush()
+}
+
+func (e *markdownExporter) Close() error {
+	for _, folder := range e.order {
+		f, err := e.zip.Create(folder.name + "/" + folder.name + ".md")
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, indexNote(folder)); err != nil {
+			return err
+		}
+	}
+	return e.zip.Close()
+}
+
+// bookmarkNote renders a bookmark as a note with YAML frontmatter. The
+// thread is read from a "thread" list in the bookmark's metadata.
+func bookmarkNote(b *models.Bookmark) string {
+	var sb strings.Builder
+	sb.WriteString("---\n")
+	fmt.Fprintf(&sb, "author: %s\n", strconv.Quote("@"+b.AuthorUsername))
+	if b.AuthorDisplayName != "" {
+		fmt.Fprintf(&sb, "author_name: %s\n", strconv.Quote(b.AuthorDisplayName))
+	}
+	fmt.Fprintf(&sb, "url: %s\n", strconv.Quote(b.TweetURL))
+	fmt.Fprintf(&sb, "date: %s\n", b.BookmarkedAt.UTC().Format(markdownDateLayout))
+	fmt.Fprintf(&sb, "tweet_id: %s\n", strconv.Quote(b.TweetID))
+	categories := make([]string, len(b.Categories))
+	for i, cat := range b.Categories {
+		categories[i] = cat.Name
+	}
+	writeYAMLList(&sb, "categories", categories)
+	writeYAMLList(&sb, "tags", noteTags(b))
+	sb.WriteString("---\n\n")
+
+	if b.Title != "" {
+		fmt.Fprintf(&sb, "# %s\n\n", b.Title)
+	}
+	if b.TweetText != "" {
+		sb.WriteString(b.TweetText)
+		sb.WriteString("\n\n")
+	}
+	for _, url := range b.MediaURLs {
+		fmt.Fprintf(&sb, "![](%s)\n\n", url)
+	}
+	if thread := threadParts(b); len(thread) > 0 {
+		sb.WriteString("## Thread\n\n")
+		for _, part := range thread {
+			sb.WriteString(part)
+			sb.WriteString("\n\n")
+		}
+	}
+	if b.Notes != "" {
+		sb.WriteString("## Notes\n\n")
+		sb.WriteString(b.Notes)
+		sb.WriteString("\n\n")
+	}
+	fmt.Fprintf(&sb, "[View on X](%s)\n", b.TweetURL)
+	return sb.String()
+}
+
+// indexNote lists the notes of a category as wiki-links.
+func indexNote(folder *markdownFolder) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "---\ncategory: %s\ncount: %d\n---\n\n", strconv.Quote(folder.category), len(folder.notes))
+	fmt.Fprintf(&sb, "# %s\n\n", folder.category)
+	for _, note := range folder.notes {
+		fmt.Fprintf(&sb, "- [[%s]]\n", note)
+	}
+	return sb.String()
+}
+
+// writeYAMLList writes a list property. Values are double-quoted; Go's
+// escapes are valid in YAML double-quoted strings.
+func writeYAMLList(sb *strings.Builder, key string, values []string) {
+	if len(values) == 0 {
+		fmt.Fprintf(sb, "%s: []\n", key)
+		return
+	}
+	fmt.Fprintf(sb, "%s:\n", key)
+	for _, v := range values {
+		fmt.Fprintf(sb, "  - %s\n", strconv.Quote(v))
+	}
+}
+
+// noteTags returns the bookmark's categories as tags, followed by the
+// hashtags in its text.
+func noteTags(b *models.Bookmark) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	add := func(tag string) {
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	for _, cat := range b.Categories {
+		add(tagName(cat.Name))
+	}
+	for _, match := range hashtagPattern.FindAllStringSubmatch(b.TweetText, -1) {
+		add(strings.ToLower(match[1]))
+	}
+	return tags
+}
+
+// tagName turns a category name into an Obsidian tag: lowercase, with runs
+// of anything but letters, digits, "_" and "-" replaced by "-". Names
+// without a letter have no tag.
+func tagName(name string) string {
+	var sb strings.Builder
+	dash := false
+	letter := false
+	for _, r := range strings.ToLower(name) {
+		letter = letter || unicode.IsLetter(r)
+		if r == '_' || r == '-' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			sb.WriteRune(r)
+			dash = false
+		} else if !dash && sb.Len() > 0 {
+			sb.WriteRune('-')
+			dash = true
+		}
+	}
+	if !letter {
+		// Obsidian does not treat numbers as tags.
+		return ""
+	}
+	return strings.TrimRight(sb.String(), "-")
+}
+
+func threadParts(b *models.Bookmark) []string {
+	raw, ok := b.Metadata["thread"].([]interface{})
+	if !ok {
+		return nil
+	}
+	var parts []string
+	for _, part := range raw {
+		if s, ok := part.(string); ok && strings.TrimSpace(s) != "" {
+			parts = append(parts, s)
+		}
+	}
+	return parts
+}
+
+// noteName names a bookmark's note after its title, or the start of its
+// text, followed by the tweet ID, which keeps names unique for wiki-links.
+func noteName(b *models.Bookmark) string {
+	title := b.Title
+	if title == "" {
+		title = strings.SplitN(strings.TrimSpace(b.TweetText), "\n", 2)[0]
+	}
+	title = fileName(title)
+	if runes := []rune(title); len(runes) > maxNoteTitleLen {
+		title = strings.TrimSpace(string(runes[:maxNoteTitleLen]))
+	}
+	if title == "" {
+		title = fileName("@" + b.AuthorUsername)
+	}
+	return fmt.Sprintf("%s (%s)", title, b.TweetID)
+}
+
+// fileName strips the characters that are not allowed in file names or
+// that break Obsidian links.
+func fileName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20 || r == 0x7F:
+			return ' '
+		case strings.ContainsRune(`/\:*?"<>|#^[]`, r):
+			return '-'
+		}
+		return r
+	}, s)
+	s = strings.Join(strings.Fields(s), " ")
+	return strings.Trim(s, ". ")
+}
@@ -0,0 +1,184 @@
+package exporters
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"twitter-bookmarks-api/models"
+
+	"github.com/google/uuid"
+)
+
+func TestMarkdownExporter(t *testing.T) {
+	goCategory := models.Category{ID: uuid.New(), Name: "Go"}
+	dbCategory := models.Category{ID: uuid.New(), Name: "Data/Bases"}
+	bookmarkedAt := time.Date(2024, 5, 1, 12, 30, 0, 0, time.FixedZone("CEST", 2*60*60))
+
+	first := models.Bookmark{
+		TweetID:           "20",
+		TweetText:         "Generics are here #golang #Go2",
+		AuthorUsername:    "jack",
+		AuthorDisplayName: "Jack",
+		TweetURL:          "https://x.com/jack/status/20",
+		MediaURLs:         []string{"https://pbs.twimg.com/media/a.jpg"},
+		BookmarkedAt:      bookmarkedAt,
+		Notes:             "Read later",
+		Metadata:          map[string]interface{}{"thread": []interface{}{"Part two", " ", "Part three"}},
+		Categories:        []models.Category{goCategory, dbCategory},
+	}
+	second := models.Bookmark{
+		TweetID:        "21",
+		Title:          "Indexes",
+		TweetText:      "B-trees",
+		AuthorUsername: "alice",
+		TweetURL:       "https://x.com/alice/status/21",
+		BookmarkedAt:   bookmarkedAt,
+		Categories:     []models.Category{dbCategory},
+	}
+	uncategorized := models.Bookmark{
+		TweetID:        "22",
+		AuthorUsername: "bob",
+		TweetURL:       "https://x.com/bob/status/22",
+		BookmarkedAt:   bookmarkedAt,
+	}
+
+	tests := []struct {
+		name      string
+		category  *models.Category
+		bookmarks []models.Bookmark
+		wantFiles []string
+		wantIn    map[string][]string
+	}{
+		{
+			name:      "empty export",
+			wantFiles: nil,
+		},
+		{
+			name:      "library",
+			bookmarks: []models.Bookmark{first, second, uncategorized},
+			wantFiles: []string{
+				"Go/Generics are here -golang -Go2 (20).md",
+				"Data-Bases/Indexes (21).md",
+				"Uncategorized/@bob (22).md",
+				"Go/Go.md",
+				"Data-Bases/Data-Bases.md",
+				"Uncategorized/Uncategorized.md",
+			},
+			wantIn: map[string][]string{
+				"Go/Generics are here -golang -Go2 (20).md": {
+					"---\nauthor: \"@jack\"\nauthor_name: \"Jack\"\nurl: \"https://x.com/jack/status/20\"\n" +
+						"date: 2024-05-01T10:30:00\ntweet_id: \"20\"\n" +
+						"categories:\n  - \"Go\"\n  - \"Data/Bases\"\n" +
+						"tags:\n  - \"go\"\n  - \"data-bases\"\n  - \"golang\"\n  - \"go2\"\n---\n\n",
+					"Generics are here #golang #Go2\n\n![](https://pbs.twimg.com/media/a.jpg)\n\n",
+					"## Thread\n\nPart two\n\nPart three\n\n## Notes\n\nRead later\n\n",
+					"[View on X](https://x.com/jack/status/20)\n",
+				},
+				"Data-Bases/Indexes (21).md": {"# Indexes\n\nB-trees\n\n"},
+				"Uncategorized/@bob (22).md": {"categories: []\ntags: []\n"},
+				"Data-Bases/Data-Bases.md": {
+					"---\ncategory: \"Data/Bases\"\ncount: 2\n---\n\n# Data/Bases\n\n" +
+						"- [[Generics are here -golang -Go2 (20)]]\n- [[Indexes (21)]]\n",
+				},
+			},
+		},
+		{
+			name:      "category export files everything under the category",
+			category:  &dbCategory,
+			bookmarks: []models.Bookmark{first, second},
+			wantFiles: []string{
+				"Data-Bases/Generics are here -golang -Go2 (20).md",
+				"Data-Bases/Indexes (21).md",
+				"Data-Bases/Data-Bases.md",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			e := newMarkdownExporter(&buf, Options{Category: tt.category})
+			for i := range tt.bookmarks {
+				if err := e.Write(&tt.bookmarks[i]); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+			}
+			if err := e.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			names, files := readZip(t, buf.Bytes())
+			if !reflect.DeepEqual(names, tt.wantFiles) {
+				t.Errorf("files = %q, want %q", names, tt.wantFiles)
+			}
+			for name, parts := range tt.wantIn {
+				for _, part := range parts {
+					if !strings.Contains(files[name], part) {
+						t.Errorf("%s = %q\nwant it to contain %q", name, files[name], part)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestMarkdownFolderNamesAreUnique(t *testing.T) {
+	e := newMarkdownExporter(&bytes.Buffer{}, Options{}).(*markdownExporter)
+	tests := []struct {
+		category string
+		want     string
+	}{
+		{"News", "News"},
+		{"news", "news (2)"},
+		{"NEWS", "NEWS (3)"},
+		{"...", "Untitled"},
+		{"###", "---"},
+	}
+	for _, tt := range tests {
+		if got := e.folder(uuid.New(), tt.category).name; got != tt.want {
+			t.Errorf("folder(%q) = %q, want %q", tt.category, got, tt.want)
+		}
+	}
+}
+
+func TestNoteName(t *testing.T) {
+	tests := []struct {
+		name     string
+		bookmark models.Bookmark
+		want     string
+	}{
+		{"title", models.Bookmark{TweetID: "1", Title: "My title", TweetText: "text"}, "My title (1)"},
+		{"first line of text", models.Bookmark{TweetID: "2", TweetText: "  first line\nsecond"}, "first line (2)"},
+		{"unsafe characters", models.Bookmark{TweetID: "3", TweetText: "a/b: c? [d]"}, "a-b- c- -d- (3)"},
+		{"long text", models.Bookmark{TweetID: "4", TweetText: strings.Repeat("word ", 20)},
+			strings.TrimSpace(strings.Repeat("word ", 12)) + " (4)"},
+		{"no text", models.Bookmark{TweetID: "5", AuthorUsername: "jack"}, "@jack (5)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := noteName(&tt.bookmark); got != tt.want {
+				t.Errorf("noteName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTagName(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Go", "go"},
+		{"Machine Learning", "machine-learning"},
+		{"C++ & Rust!", "c-rust"},
+		{"snake_case-name", "snake_case-name"},
+		{"Año 2024", "año-2024"},
+		{"2024", ""},
+		{"!!!", ""},
+	}
+	for _, tt := range tests {
+		if got := tagName(tt.in); got != tt.want {
+			t.Errorf("tagName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package exporters
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"twitter-bookmarks-api/models"
+)
+
+const (
+	// xlsxMaxRows is the most rows a sheet can hold, header included.
+	xlsxMaxRows = 1048576
+	// xlsxMaxCellLen is the most characters a cell can hold.
+	xlsxMaxCellLen = 32767
+	// xlsxMaxSheetNameLen is the longest sheet name Excel accepts.
+	xlsxMaxSheetNameLen = 31
+
+	// Styles defined in xlsxStyles.
+	xlsxStyleDate   = 1
+	xlsxStyleHeader = 2
+)
+
+var errTooManyRows = errors.New("export exceeds the row limit of an xlsx sheet")
+
+// excelEpoch is day zero of Excel's date serial numbers.
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+</Types>`
+
+const xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+const xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+</Relationships>`
+
+const xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`
+
+// xlsxStyles defines the plain style, a date and time style using Excel's
+// built-in format 22 and a bold style for the header row.
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="3">
+<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>
+<xf numFmtId="22" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>
+</cellXfs>
+</styleSheet>`
+
+// xlsxSheetStart freezes the header row.
+const xlsxSheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>
+<sheetData>`
+
+const xlsxSheetEnd = `</sheetData></worksheet>`
+
+// xlsxExporter writes a workbook with a single sheet. The fixed parts of
+// the package are written first, so that the sheet, the last file in the
+// zip, can be streamed row by row. Text is stored in inline strings, which
+// spreadsheets never evaluate as formulas, and times as date cells.
+type xlsxExporter struct {
+	zip       *zip.Writer
+	sheet     *bufio.Writer
+	sheetName string
+	columns   []string
+	rows      int
+}
+
+func newXLSXExporter(w io.Writer, opts Options) Exporter {
+	name := "Bookmarks"
+	if opts.Category != nil {
+		name = sheetName(opts.Category.Name)
+	}
+	return &xlsxExporter{zip: zip.NewWriter(w), sheetName: name, columns: opts.Columns}
+}
+
+func (e *xlsxExporter) begin() error {
+	if e.sheet != nil {
+		return nil
+	}
+	var escapedName strings.Builder
+	xml.EscapeText(&escapedName, []byte(e.sheetName))
+	parts := []struct{ name, body string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", fmt.Sprintf(xlsxWorkbook, escapedName.String())},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for _, part := range parts {
+		f, err := e.zip.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, part.body); err != nil {
+			return err
+		}
+	}
+
+	f, err := e.zip.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	e.sheet = bufio.NewWriter(f)
+	e.sheet.WriteString(xlsxSheetStart)
+
+	header := make([]interface{}, len(e.columns))
+	for i, name := range e.columns {
+		header[i] = name
+	}
+	return e.writeRow(header, xlsxStyleHeader)
+}
+
+func (e *xlsxExporter) writeRow(values []interface{}, style int) error {
+	if e.rows >= xlsxMaxRows {
+		return errTooManyRows
+	}
+	e.rows++
+	fmt.Fprintf(e.sheet, `<row r="%d">`, e.rows)
+	for i, value := range values {
+		ref := columnLetter(i) + strconv.Itoa(e.rows)
+		switch v := value.(type) {
+		case string:
+			fmt.Fprintf(e.sheet, `<c r="%s" t="inlineStr"`, ref)
+			if style != 0 {
+				fmt.Fprintf(e.sheet, ` s="%d"`, style)
+			}
+			e.sheet.WriteString(`><is><t xml:space="preserve">`)
+			xml.EscapeText(e.sheet, []byte(cellText(v)))
+			e.sheet.WriteString(`</t></is></c>`)
+		case time.Time:
+			days := v.Sub(excelEpoch).Seconds() / (24 * 60 * 60)
+			fmt.Fprintf(e.sheet, `<c r="%s" s="%d"><v>%s</v></c>`, ref, xlsxStyleDate, strconv.FormatFloat(days, 'f', -1, 64))
+		}
+	}
+	_, err := e.sheet.WriteString(`</row>`)
+	return err
+}
+
+func (e *xlsxExporter) Write(b *models.Bookmark) error {
+	if err := e.begin(); err != nil {
+		return err
+	}
+	return e.writeRow(row(b, e.columns), 0)
+}
+
+func (e *xlsxExporter) Flush() error {
+	if e.sheet == nil {
+		return nil
+	}
+	if err := e.sheet.Flush(); err != nil {
+		return err
+	}
+	return e.zip.Flush()
+}
+
+func (e *xlsxExporter) Close() error {
+	if err := e.begin(); err != nil {
+		return err
+	}
+	e.sheet.WriteString(xlsxSheetEnd)
+	if err := e.sheet.Flush(); err != nil {
+		return err
+	}
+	return e.zip.Close()
+}
+
+// columnLetter returns the letters naming the zero-based column i: A to Z,
+// then AA and so on.
+func columnLetter(i int) string {
+	var letters []byte
+	for i++; i > 0; i = (i - 1) / 26 {
+		letters = append([]byte{byte('A' + (i-1)%26)}, letters...)
+	}
+	return string(letters)
+}
+
+// cellText drops the characters XML cannot hold and cuts text to the length
+// a cell can hold.
+func cellText(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' || r == 0xFFFE || r == 0xFFFF {
+			return -1
+		}
+		return r
+	}, s)
+	if runes := []rune(s); len(runes) > xlsxMaxCellLen {
+		s = string(runes[:xlsxMaxCellLen])
+	}
+	return s
+}
+
+// sheetName turns a category name into a valid sheet name.
+func sheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, cellText(name))
+	name = strings.Trim(strings.TrimSpace(name), "'")
+	if runes := []rune(name); len(runes) > xlsxMaxSheetNameLen {
+		name = string(runes[:xlsxMaxSheetNameLen])
+	}
+	if name == "" {
+		return "Bookmarks"
+	}
+	return name
+}
@@ -0,0 +1,169 @@
+package exporters
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+	"twitter-bookmarks-api/models"
+)
+
+// readZip returns the files of a zip by name, in the order they were
+// written.
+func readZip(t *testing.T, data []byte) ([]string, map[string]string) {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("invalid zip: %v", err)
+	}
+	var names []string
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, f.Name)
+		files[f.Name] = string(body)
+	}
+	return names, files
+}
+
+func TestXLSXExporter(t *testing.T) {
+	bookmarkedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		category  *models.Category
+		columns   []string
+		bookmarks []models.Bookmark
+		wantSheet string
+		wantCells []string
+	}{
+		{
+			name:      "empty export has the header",
+			columns:   []string{"tweet_id", "tweet_text"},
+			wantSheet: "Bookmarks",
+			wantCells: []string{
+				`<row r="1"><c r="A1" t="inlineStr" s="2"><is><t xml:space="preserve">tweet_id</t></is></c>` +
+					`<c r="B1" t="inlineStr" s="2"><is><t xml:space="preserve">tweet_text</t></is></c></row>`,
+			},
+		},
+		{
+			name:     "text and dates",
+			category: &models.Category{Name: "Go / Rust"},
+			columns:  []string{"tweet_id", "tweet_text", "bookmarked_at", "read_at"},
+			bookmarks: []models.Bookmark{{
+				TweetID:      "20",
+				TweetText:    "=1+1 <b> & \x01",
+				BookmarkedAt: bookmarkedAt,
+			}},
+			wantSheet: "Go _ Rust",
+			wantCells: []string{
+				`<c r="A2" t="inlineStr"><is><t xml:space="preserve">20</t></is></c>`,
+				// Inline strings are never evaluated, so formulas stay text.
+				`<c r="B2" t="inlineStr"><is><t xml:space="preserve">=1+1 &lt;b&gt; &amp; </t></is></c>`,
+				`<c r="C2" s="1"><v>45292.5</v></c></row>`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			e := newXLSXExporter(&buf, Options{Category: tt.category, Columns: tt.columns})
+			for i := range tt.bookmarks {
+				if err := e.Write(&tt.bookmarks[i]); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+			}
+			if err := e.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			names, files := readZip(t, buf.Bytes())
+			if last := names[len(names)-1]; last != "xl/worksheets/sheet1.xml" {
+				t.Errorf("last file = %s, want the sheet", last)
+			}
+			for _, part := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml"} {
+				if _, ok := files[part]; !ok {
+					t.Errorf("missing %s", part)
+				}
+			}
+			if want := `<sheet name="` + tt.wantSheet + `"`; !strings.Contains(files["xl/workbook.xml"], want) {
+				t.Errorf("workbook = %s, want %s", files["xl/workbook.xml"], want)
+			}
+			sheet := files["xl/worksheets/sheet1.xml"]
+			if !strings.HasSuffix(sheet, xlsxSheetEnd) {
+				t.Errorf("sheet is not closed: %s", sheet)
+			}
+			for _, cell := range tt.wantCells {
+				if !strings.Contains(sheet, cell) {
+					t.Errorf("sheet = %s\nwant it to contain %s", sheet, cell)
+				}
+			}
+		})
+	}
+}
+
+func TestColumnLetter(t *testing.T) {
+	tests := []struct {
+		i    int
+		want string
+	}{
+		{0, "A"},
+		{25, "Z"},
+		{26, "AA"},
+		{51, "AZ"},
+		{52, "BA"},
+		{701, "ZZ"},
+		{702, "AAA"},
+	}
+	for _, tt := range tests {
+		if got := columnLetter(tt.i); got != tt.want {
+			t.Errorf("columnLetter(%d) = %q, want %q", tt.i, got, tt.want)
+		}
+	}
+}
+
+func TestCellText(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"plain", "hello", "hello"},
+		{"keeps whitespace controls", "a\tb\nc\r", "a\tb\nc\r"},
+		{"drops other controls", "a\x00b\x1fc", "abc"},
+		{"drops noncharacters", "a\ufffeb\uffff", "ab"},
+		{"cuts long text", strings.Repeat("é", xlsxMaxCellLen+5), strings.Repeat("é", xlsxMaxCellLen)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cellText(tt.in); got != tt.want {
+				t.Errorf("cellText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSheetName(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Reading", "Reading"},
+		{"a/b\\c[d]:e*f?g", "a_b_c_d__e_f_g"},
+		{"'quoted'", "quoted"},
+		{"  ", "Bookmarks"},
+		{"A very long category name that does not fit", "A very long category name that "},
+	}
+	for _, tt := range tests {
+		if got := sheetName(tt.in); got != tt.want {
+			t.Errorf("sheetName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package feeds
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Links      []atomLink     `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     atomPerson     `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomContent    `xml:"content"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// renderAtom writes an Atom 1.0 feed.
+func renderAtom(w io.Writer, feed *Feed) error {
+	doc := atomFeed{
+		Title:   feed.Title,
+		ID:      feed.ID,
+		Updated: feed.updated().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: feed.FeedURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: feed.HomeURL, Rel: "alternate", Type: "text/html"},
+		},
+	}
+	for i := range feed.Bookmarks {
+		b := &feed.Bookmarks[i]
+		entry := atomEntry{
+			Title:     itemTitle(b),
+			ID:        itemID(b),
+			Links:     []atomLink{{Href: b.TweetURL, Rel: "alternate"}},
+			Published: b.BookmarkedAt.UTC().Format(time.RFC3339),
+			Updated:   b.UpdatedAt.UTC().Format(time.RFC3339),
+			Author:    atomPerson{Name: itemAuthor(b)},
+			Content:   atomContent{Type: "html", Value: contentHTML(b)},
+		}
+		for _, name := range categoryNames(b) {
+			entry.Categories = append(entry.Categories, atomCategory{Term: name})
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(doc)
+}
@@ -0,0 +1,124 @@
+// Package feeds renders bookmarks as RSS 2.0, Atom and JSON Feed documents
+// for feed readers.
+package feeds
+
+import (
+	"html"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"twitter-bookmarks-api/models"
+)
+
+// maxItemTitleLen is how much of a tweet titles its item when the bookmark
+// has no title of its own.
+const maxItemTitleLen = 100
+
+// Feed is a feed of bookmarks, newest first.
+type Feed struct {
+	// ID identifies the feed for as long as it exists, as a URI.
+	ID        string
+	Title     string
+	HomeURL   string
+	FeedURL   string
+	Bookmarks []models.Bookmark
+}
+
+// Format is a feed format.
+type Format struct {
+	ContentType string
+	Render      func(w io.Writer, feed *Feed) error
+}
+
+var formats = map[string]Format{
+	"rss":  {ContentType: "application/rss+xml; charset=utf-8", Render: renderRSS},
+	"atom": {ContentType: "application/atom+xml; charset=utf-8", Render: renderAtom},
+	"json": {ContentType: "application/feed+json; charset=utf-8", Render: renderJSON},
+}
+
+// Get returns the format named name.
+func Get(name string) (Format, bool) {
+	format, ok := formats[name]
+	return format, ok
+}
+
+// Formats lists the supported formats.
+func Formats() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// updated is when the feed last changed: the latest change to any of its
+// bookmarks, or now for an empty feed.
+func (f *Feed) updated() time.Time {
+	var latest time.Time
+	for _, b := range f.Bookmarks {
+		if b.UpdatedAt.After(latest) {
+			latest = b.UpdatedAt
+		}
+		if b.BookmarkedAt.After(latest) {
+			latest = b.BookmarkedAt
+		}
+	}
+	if latest.IsZero() {
+		return time.Now().UTC()
+	}
+	return latest.UTC()
+}
+
+// itemID identifies a bookmark's item across fetches.
+func itemID(b *models.Bookmark) string {
+	return "urn:uuid:" + b.ID.String()
+}
+
+// itemTitle is the bookmark's title, or its author and the start of its
+// text.
+func itemTitle(b *models.Bookmark) string {
+	if b.Title != "" {
+		return b.Title
+	}
+	text := strings.Join(strings.Fields(b.TweetText), " ")
+	if runes := []rune(text); len(runes) > maxItemTitleLen {
+		text = string(runes[:maxItemTitleLen]) + "…"
+	}
+	if text == "" {
+		return "@" + b.AuthorUsername
+	}
+	return "@" + b.AuthorUsername + ": " + text
+}
+
+// itemAuthor names the tweet's author.
+func itemAuthor(b *models.Bookmark) string {
+	if b.AuthorDisplayName != "" {
+		return b.AuthorDisplayName + " (@" + b.AuthorUsername + ")"
+	}
+	return "@" + b.AuthorUsername
+}
+
+func categoryNames(b *models.Bookmark) []string {
+	names := make([]string, len(b.Categories))
+	for i, cat := range b.Categories {
+		names[i] = cat.Name
+	}
+	return names
+}
+
+// contentHTML renders the tweet text with its line breaks, followed by its
+// media.
+func contentHTML(b *models.Bookmark) string {
+	var sb strings.Builder
+	sb.WriteString("<p>")
+	sb.WriteString(strings.ReplaceAll(html.EscapeString(b.TweetText), "\n", "<br>"))
+	sb.WriteString("</p>")
+	for _, url := range b.MediaURLs {
+		sb.WriteString(`<p><img src="`)
+		sb.WriteString(html.EscapeString(url))
+		sb.WriteString(`"></p>`)
+	}
+	return sb.String()
+}
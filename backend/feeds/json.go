@@ -0,0 +1,66 @@
+package feeds
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+type jsonFeed struct {
+	Version     string     `json:"version"`
+	Title       string     `json:"title"`
+	HomePageURL string     `json:"home_page_url"`
+	FeedURL     string     `json:"feed_url"`
+	Items       []jsonItem `json:"items"`
+}
+
+type jsonItem struct {
+	ID            string       `json:"id"`
+	URL           string       `json:"url"`
+	Title         string       `json:"title"`
+	ContentText   string       `json:"content_text"`
+	ContentHTML   string       `json:"content_html"`
+	Image         string       `json:"image,omitempty"`
+	DatePublished string       `json:"date_published"`
+	DateModified  string       `json:"date_modified"`
+	Authors       []jsonAuthor `json:"authors"`
+	Tags          []string     `json:"tags,omitempty"`
+}
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+// renderJSON writes a JSON Feed 1.1 document.
+func renderJSON(w io.Writer, feed *Feed) error {
+	doc := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       feed.Title,
+		HomePageURL: feed.HomeURL,
+		FeedURL:     feed.FeedURL,
+		Items:       []jsonItem{},
+	}
+	for i := range feed.Bookmarks {
+		b := &feed.Bookmarks[i]
+		item := jsonItem{
+			ID:            itemID(b),
+			URL:           b.TweetURL,
+			Title:         itemTitle(b),
+			ContentText:   b.TweetText,
+			ContentHTML:   contentHTML(b),
+			DatePublished: b.BookmarkedAt.UTC().Format(time.RFC3339),
+			DateModified:  b.UpdatedAt.UTC().Format(time.RFC3339),
+			Authors:       []jsonAuthor{{Name: itemAuthor(b)}},
+			Tags:          categoryNames(b),
+		}
+		if b.AuthorUsername != "" {
+			item.Authors[0].URL = "https://x.com/" + b.AuthorUsername
+		}
+		if len(b.MediaURLs) > 0 {
+			item.Image = b.MediaURLs[0]
+		}
+		doc.Items = append(doc.Items, item)
+	}
+	return json.NewEncoder(w).Encode(doc)
+}
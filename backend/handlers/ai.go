@@ -30,19 +30,24 @@ func AutoCategorizeBookmarks(c *gin.Context) {
 		return
 	}
 
-	categorizedCount, newCategoriesCount, err := services.CategorizeBookmarksForUser(c.Request.Context(), userID, bookmarks)
+	result, err := services.CategorizeBookmarksForUser(c.Request.Context(), userID, bookmarks)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to categorize bookmarks"})
 		return
 	}
 
+	data := gin.H{
+		"categorized":     result.Categorized,
+		"new_categories":  result.NewCategories,
+		"total_processed": len(bookmarks),
+	}
+	if result.Categorized > 0 || result.NewCategories > 0 {
+		recordOperation(c, userID, models.OperationAICategorize, result.Inverse())
+	}
+
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Message: "Auto-categorization completed",
-		Data: map[string]int{
-			"categorized":     categorizedCount,
-			"new_categories":  newCategoriesCount,
-			"total_processed": len(bookmarks),
-		},
+		Data:    data,
 	})
 }
 
@@ -63,16 +68,19 @@ func CategorizeBookmark(c *gin.Context) {
 	}
 
 	// Use services helper to categorize this single bookmark
-	categorized, _, err := services.CategorizeBookmarksForUser(c.Request.Context(), userID, []models.Bookmark{*bookmark})
+	result, err := services.CategorizeBookmarksForUser(c.Request.Context(), userID, []models.Bookmark{*bookmark})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "AI categorization failed"})
 		return
 	}
 
+	data := gin.H{"categorized": result.Categorized}
+	if result.Categorized > 0 || result.NewCategories > 0 {
+		recordOperation(c, userID, models.OperationAICategorize, result.Inverse())
+	}
+
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Message: "Categorization completed",
-		Data: map[string]int{
-			"categorized": categorized,
-		},
+		Data:    data,
 	})
 }
@@ -1,11 +1,13 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"os"
 	"twitter-bookmarks-api/auth"
 	"twitter-bookmarks-api/database"
 	"twitter-bookmarks-api/models"
+	"twitter-bookmarks-api/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -50,6 +52,22 @@ func TwitterCallback(c *gin.Context) {
 		return
 	}
 
+	// Keep the token for syncing bookmarks from the X API if the server
+	// syncs them. Signing in still works without it.
+	if services.XSyncEnabled() {
+		xToken := models.XToken{
+			AccessToken:  token.AccessToken,
+			RefreshToken: token.RefreshToken,
+			TokenType:    token.TokenType,
+			Expiry:       token.Expiry,
+		}
+		if err := database.SaveXToken(c.Request.Context(), user.ID, xToken); err != nil {
+			fmt.Printf("failed to save X token for user %s: %v\n", user.ID, err)
+		} else {
+			services.RequestXSync(c.Request.Context(), user.ID)
+		}
+	}
+
 	jwtToken, err := auth.GenerateJWT(user.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to generate token"})
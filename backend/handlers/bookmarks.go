@@ -1,13 +1,17 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 	"twitter-bookmarks-api/database"
+	"twitter-bookmarks-api/langdetect"
 	"twitter-bookmarks-api/models"
 	"twitter-bookmarks-api/services"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -18,7 +22,6 @@ func GetBookmarks(c *gin.Context) {
 
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
-	categoryIDStr := c.Query("category_id")
 
 	if page < 1 {
 		page = 1
@@ -33,15 +36,7 @@ func GetBookmarks(c *gin.Context) {
 		Offset:   (page - 1) * pageSize,
 	}
 
-	var categoryID *uuid.UUID
-	if categoryIDStr != "" {
-		id, err := uuid.Parse(categoryIDStr)
-		if err == nil {
-			categoryID = &id
-		}
-	}
-
-	response, err := database.GetBookmarksByUserID(c.Request.Context(), userID, params, categoryID)
+	response, err := database.GetBookmarksByUserID(c.Request.Context(), userID, params, bookmarkFilterFromQuery(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to fetch bookmarks"})
 		return
@@ -50,6 +45,54 @@ func GetBookmarks(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// bookmarkFilterFromQuery reads the filters shared by the list and search
+// endpoints. Invalid values are ignored rather than rejected.
+func bookmarkFilterFromQuery(c *gin.Context) models.BookmarkFilter {
+	return newBookmarkFilter(c.Query("category_id"), c.Query("status"), c.Query("lang"),
+		parseOptionalBool(c.Query("archived")), parseOptionalBool(c.Query("read")))
+}
+
+func newBookmarkFilter(categoryID, status, lang string, archived, read *bool) models.BookmarkFilter {
+	filter := models.BookmarkFilter{Archived: archived, Read: read}
+
+	if id, err := uuid.Parse(categoryID); err == nil {
+		filter.CategoryID = &id
+	}
+	if models.IsValidBookmarkStatus(status) {
+		filter.Status = status
+	}
+	if langdetect.IsSupported(lang) {
+		filter.Lang = lang
+	}
+	return filter
+}
+
+func parseOptionalBool(value string) *bool {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil
+	}
+	return &b
+}
+
+// parseSearchOperators pulls operators such as "status:deleted" or "lang:es" out of a
+// search query into filter and returns the remaining free text.
+func parseSearchOperators(query string, filter *models.BookmarkFilter) string {
+	var terms []string
+	for _, term := range strings.Fields(query) {
+		key, value, found := strings.Cut(term, ":")
+		switch {
+		case found && strings.EqualFold(key, "status") && models.IsValidBookmarkStatus(strings.ToLower(value)):
+			filter.Status = strings.ToLower(value)
+		case found && strings.EqualFold(key, "lang") && langdetect.IsSupported(strings.ToLower(value)):
+			filter.Lang = strings.ToLower(value)
+		default:
+			terms = append(terms, term)
+		}
+	}
+	return strings.Join(terms, " ")
+}
+
 func ImportBookmarks(c *gin.Context) {
 	userID := c.MustGet("userID").(uuid.UUID)
 
@@ -59,55 +102,25 @@ func ImportBookmarks(c *gin.Context) {
 		return
 	}
 
-	importedCount := 0
-	duplicateCount := 0
-	newBookmarks := make([]models.Bookmark, 0, len(importData.Bookmarks))
-
-	for _, item := range importData.Bookmarks {
-		bookmarkedAt, err := time.Parse(time.RFC3339, item.BookmarkedAt)
-		if err != nil {
-			bookmarkedAt = time.Now()
-		}
+	runImport(c, userID, importData.Bookmarks, importSource(c, models.ImportSourceAPI))
+}
 
-		bookmark := &models.Bookmark{
-			UserID:            userID,
-			TweetID:           item.TweetID,
-			TweetText:         item.TweetText,
-			AuthorUsername:    item.AuthorUsername,
-			AuthorDisplayName: item.AuthorDisplayName,
-			TweetURL:          item.TweetURL,
-			MediaURLs:         item.MediaURLs,
-			BookmarkedAt:      bookmarkedAt,
-		}
+// syncHistoryLimit is how many recent sync sessions GetSyncState returns.
+const syncHistoryLimit = 20
 
-		err = database.CreateBookmark(c.Request.Context(), bookmark)
-		if err != nil {
-			duplicateCount++
-		} else {
-			importedCount++
-			newBookmarks = append(newBookmarks, *bookmark)
-		}
-	}
+// GetSyncState returns the tweets the last complete extension sync started
+// with and when it finished, so the extension can stop scrolling once it
+// reaches them, along with the user's recent imports.
+func GetSyncState(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
 
-	autoCategorized := 0
-	if importedCount > 0 {
-		user, err := database.GetUserByID(c.Request.Context(), userID)
-		if err == nil && user != nil && user.AutoCategorize {
-			categorized, _, err := services.CategorizeBookmarksForUser(c.Request.Context(), userID, newBookmarks)
-			if err != nil {
-				fmt.Printf("auto categorize failed: %v\n", err)
-			} else {
-				autoCategorized = categorized
-			}
-		}
+	state, err := database.GetSyncState(c.Request.Context(), userID, syncHistoryLimit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to fetch sync state"})
+		return
 	}
 
-	c.JSON(http.StatusOK, models.ImportResponse{
-		Message:         "Import completed",
-		ImportedCount:   importedCount,
-		DuplicateCount:  duplicateCount,
-		AutoCategorized: autoCategorized,
-	})
+	c.JSON(http.StatusOK, state)
 }
 
 func DeleteBookmark(c *gin.Context) {
@@ -118,15 +131,132 @@ func DeleteBookmark(c *gin.Context) {
 		return
 	}
 
-	err = database.DeleteBookmark(c.Request.Context(), bookmarkID, userID)
-	if err != nil {
+	operationID, err := database.DeleteBookmark(c.Request.Context(), bookmarkID, userID)
+	if errors.Is(err, database.ErrBookmarkNotFound) {
 		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Bookmark not found"})
 		return
 	}
+	if err != nil {
+		fmt.Printf("failed to delete bookmark %s: %v\n", bookmarkID, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to delete bookmark"})
+		return
+	}
+	c.Header(operationHeader, operationID.String())
 
 	c.JSON(http.StatusOK, models.SuccessResponse{Message: "Bookmark deleted successfully"})
 }
 
+const (
+	maxTitleLength       = 300
+	maxTweetTextLength   = 25000
+	maxNotesLength       = 10000
+	maxMetadataKeys      = 50
+	maxMetadataKeyLength = 64
+)
+
+func UpdateBookmark(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	bookmarkID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid bookmark ID"})
+		return
+	}
+
+	var req models.UpdateBookmarkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+
+	if req.Title == nil && req.TweetText == nil && req.BookmarkedAt == nil && req.Notes == nil && req.Metadata == nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No fields to update"})
+		return
+	}
+
+	update, fieldErrors := validateBookmarkUpdate(req)
+	if len(fieldErrors) > 0 {
+		c.JSON(http.StatusBadRequest, models.ValidationErrorResponse{Error: "Invalid bookmark fields", Fields: fieldErrors})
+		return
+	}
+
+	operationID, err := database.UpdateBookmark(c.Request.Context(), bookmarkID, userID, update)
+	if errors.Is(err, database.ErrBookmarkNotFound) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Bookmark not found"})
+		return
+	}
+	if err != nil {
+		fmt.Printf("failed to update bookmark %s: %v\n", bookmarkID, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to update bookmark"})
+		return
+	}
+	c.Header(operationHeader, operationID.String())
+
+	bookmark, err := database.GetBookmarkByID(c.Request.Context(), bookmarkID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to fetch bookmark"})
+		return
+	}
+
+	c.JSON(http.StatusOK, bookmark)
+}
+
+// validateBookmarkUpdate checks every field of req and returns the parsed
+// update together with an error message per invalid field.
+func validateBookmarkUpdate(req models.UpdateBookmarkRequest) (models.BookmarkUpdate, map[string]string) {
+	update := models.BookmarkUpdate{
+		Title:     req.Title,
+		TweetText: req.TweetText,
+		Notes:     req.Notes,
+		Metadata:  req.Metadata,
+	}
+	fieldErrors := make(map[string]string)
+
+	if req.Title != nil {
+		title := strings.TrimSpace(*req.Title)
+		update.Title = &title
+		if utf8.RuneCountInString(title) > maxTitleLength {
+			fieldErrors["title"] = fmt.Sprintf("must be at most %d characters", maxTitleLength)
+		}
+	}
+
+	if req.TweetText != nil {
+		if strings.TrimSpace(*req.TweetText) == "" {
+			fieldErrors["tweet_text"] = "must not be empty"
+		} else if utf8.RuneCountInString(*req.TweetText) > maxTweetTextLength {
+			fieldErrors["tweet_text"] = fmt.Sprintf("must be at most %d characters", maxTweetTextLength)
+		}
+		update.Lang = langdetect.Detect(*req.TweetText)
+	}
+
+	if req.BookmarkedAt != nil {
+		bookmarkedAt, err := time.Parse(time.RFC3339, *req.BookmarkedAt)
+		if err != nil {
+			fieldErrors["bookmarked_at"] = "must be an RFC 3339 timestamp"
+		} else if bookmarkedAt.After(time.Now().Add(time.Minute)) {
+			fieldErrors["bookmarked_at"] = "must not be in the future"
+		} else {
+			update.BookmarkedAt = &bookmarkedAt
+		}
+	}
+
+	if req.Notes != nil && utf8.RuneCountInString(*req.Notes) > maxNotesLength {
+		fieldErrors["notes"] = fmt.Sprintf("must be at most %d characters", maxNotesLength)
+	}
+
+	if req.Metadata != nil {
+		if len(req.Metadata) > maxMetadataKeys {
+			fieldErrors["metadata"] = fmt.Sprintf("must have at most %d keys", maxMetadataKeys)
+		}
+		for key := range req.Metadata {
+			if strings.TrimSpace(key) == "" || utf8.RuneCountInString(key) > maxMetadataKeyLength {
+				fieldErrors["metadata."+key] = fmt.Sprintf("keys must be 1 to %d characters", maxMetadataKeyLength)
+			}
+		}
+	}
+
+	return update, fieldErrors
+}
+
 func SearchBookmarks(c *gin.Context) {
 	userID := c.MustGet("userID").(uuid.UUID)
 	query := c.Query("q")
@@ -136,6 +266,9 @@ func SearchBookmarks(c *gin.Context) {
 		return
 	}
 
+	filter := bookmarkFilterFromQuery(c)
+	query = parseSearchOperators(query, &filter)
+
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
 
@@ -152,7 +285,7 @@ func SearchBookmarks(c *gin.Context) {
 		Offset:   (page - 1) * pageSize,
 	}
 
-	response, err := database.SearchBookmarks(c.Request.Context(), userID, query, params)
+	response, err := database.SearchBookmarks(c.Request.Context(), userID, query, params, filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to search bookmarks"})
 		return
@@ -181,12 +314,18 @@ func AssignCategory(c *gin.Context) {
 		return
 	}
 
-	err = database.AssignBookmarkToCategory(c.Request.Context(), bookmarkID, categoryID, userID)
+	assigned, err := database.AssignBookmarkToCategory(c.Request.Context(), bookmarkID, categoryID, userID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
 		return
 	}
 
+	if assigned {
+		recordOperation(c, userID, models.OperationAssignCategory, models.OperationInverse{
+			RemoveAssignments: []models.BookmarkCategory{{BookmarkID: bookmarkID, CategoryID: categoryID}},
+		})
+	}
+
 	c.JSON(http.StatusOK, models.SuccessResponse{Message: "Category assigned successfully"})
 }
 
@@ -204,11 +343,196 @@ func RemoveCategory(c *gin.Context) {
 		return
 	}
 
-	err = database.RemoveBookmarkFromCategory(c.Request.Context(), bookmarkID, categoryID, userID)
+	removed, err := database.RemoveBookmarkFromCategory(c.Request.Context(), bookmarkID, categoryID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to remove category"})
 		return
 	}
 
+	if removed {
+		recordOperation(c, userID, models.OperationRemoveCategory, models.OperationInverse{
+			AddAssignments: []models.BookmarkCategory{{BookmarkID: bookmarkID, CategoryID: categoryID}},
+		})
+	}
+
 	c.JSON(http.StatusOK, models.SuccessResponse{Message: "Category removed successfully"})
 }
+
+// GetDeadBookmarks reports bookmarks whose tweet or links stopped resolving on their latest status check
+func GetDeadBookmarks(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	bookmarks, err := database.GetDeadBookmarks(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to fetch dead bookmarks"})
+		return
+	}
+
+	if bookmarks == nil {
+		bookmarks = []models.DeadBookmark{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"bookmarks": bookmarks, "count": len(bookmarks)})
+}
+
+const maxBulkIDs = 10000
+
+// BulkBookmarks applies one action to many bookmarks at once, selected either
+// by id or by the same filters and search syntax as the list endpoints.
+func BulkBookmarks(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	var req models.BulkActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+
+	action := models.BulkAction{Action: req.Action}
+	switch req.Action {
+	case models.BulkActionDelete, models.BulkActionArchive, models.BulkActionMarkRead:
+	case models.BulkActionAssignCategory, models.BulkActionRemoveCategory:
+		categoryID, err := uuid.Parse(req.CategoryID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid category ID"})
+			return
+		}
+		action.CategoryID = &categoryID
+	default:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Unknown bulk action"})
+		return
+	}
+
+	switch {
+	case req.Filter != nil && len(req.IDs) > 0:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Provide either ids or filter, not both"})
+		return
+	case req.Filter != nil:
+		f := req.Filter
+		filter := newBookmarkFilter(f.CategoryID, f.Status, f.Lang, f.Archived, f.Read)
+		action.Query = parseSearchOperators(f.Query, &filter)
+		action.Filter = &filter
+	case len(req.IDs) == 0:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Provide ids or filter"})
+		return
+	case len(req.IDs) > maxBulkIDs:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: fmt.Sprintf("At most %d ids per request", maxBulkIDs)})
+		return
+	default:
+		for _, raw := range req.IDs {
+			id, err := uuid.Parse(raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid bookmark ID: " + raw})
+				return
+			}
+			action.IDs = append(action.IDs, id)
+		}
+	}
+
+	result, err := database.ExecuteBulkAction(c.Request.Context(), userID, action)
+	if err != nil {
+		switch err.Error() {
+		case "bookmark not found", "category not found", "unauthorized":
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Bulk action failed"})
+		}
+		return
+	}
+
+	if result.OperationID != nil {
+		c.Header(operationHeader, result.OperationID.String())
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetBookmarkRevisions lists earlier imported versions of a bookmark with field-level diffs
+func GetBookmarkRevisions(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	bookmarkID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid bookmark ID"})
+		return
+	}
+
+	revisions, err := database.GetBookmarkRevisions(c.Request.Context(), bookmarkID, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Bookmark not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revisions": revisions})
+}
+
+// GetDuplicates lists clusters of bookmarks with near-identical text or shared links
+func GetDuplicates(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	clusters, err := services.FindDuplicateClusters(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to find duplicates"})
+		return
+	}
+
+	if clusters == nil {
+		clusters = []models.DuplicateCluster{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"clusters": clusters, "count": len(clusters)})
+}
+
+const maxMergeIDs = 100
+
+// MergeDuplicates keeps one bookmark and folds the categories and notes of
+// the others into it, moving them to the trash.
+func MergeDuplicates(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	var req models.MergeDuplicatesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+
+	keepID, err := uuid.Parse(req.KeepID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid bookmark ID: " + req.KeepID})
+		return
+	}
+	if len(req.MergeIDs) > maxMergeIDs {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: fmt.Sprintf("At most %d ids per merge", maxMergeIDs)})
+		return
+	}
+
+	seen := map[uuid.UUID]bool{keepID: true}
+	var mergeIDs []uuid.UUID
+	for _, raw := range req.MergeIDs {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid bookmark ID: " + raw})
+			return
+		}
+		if !seen[id] {
+			seen[id] = true
+			mergeIDs = append(mergeIDs, id)
+		}
+	}
+	if len(mergeIDs) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Provide at least one other bookmark to merge"})
+		return
+	}
+
+	bookmark, operationID, err := database.MergeBookmarks(c.Request.Context(), userID, keepID, mergeIDs)
+	if err != nil {
+		switch err.Error() {
+		case "bookmark not found", "unauthorized":
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to merge bookmarks"})
+		}
+		return
+	}
+
+	c.Header(operationHeader, operationID.String())
+	c.JSON(http.StatusOK, gin.H{"bookmark": bookmark, "merged": len(mergeIDs)})
+}
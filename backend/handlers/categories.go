@@ -54,6 +54,10 @@ func CreateCategory(c *gin.Context) {
 		return
 	}
 
+	recordOperation(c, userID, models.OperationCreateCategory, models.OperationInverse{
+		TrashCategories: []uuid.UUID{category.ID},
+	})
+
 	c.JSON(http.StatusCreated, category)
 }
 
@@ -71,12 +75,22 @@ func UpdateCategory(c *gin.Context) {
 		return
 	}
 
+	previous, err := database.GetCategoryByID(c.Request.Context(), categoryID, userID)
+	if err != nil || previous == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Category not found"})
+		return
+	}
+
 	err = database.UpdateCategory(c.Request.Context(), categoryID, userID, req.Name, req.Color, req.Icon)
 	if err != nil {
 		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Category not found"})
 		return
 	}
 
+	recordOperation(c, userID, models.OperationUpdateCategory, models.OperationInverse{
+		CategoryEdits: []models.Category{*previous},
+	})
+
 	category, _ := database.GetCategoryByID(c.Request.Context(), categoryID, userID)
 	c.JSON(http.StatusOK, category)
 }
@@ -95,5 +109,9 @@ func DeleteCategory(c *gin.Context) {
 		return
 	}
 
+	recordOperation(c, userID, models.OperationDeleteCategory, models.OperationInverse{
+		RestoreCategories: []uuid.UUID{categoryID},
+	})
+
 	c.JSON(http.StatusOK, models.SuccessResponse{Message: "Category deleted successfully"})
 }
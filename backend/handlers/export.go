@@ -1,26 +1,25 @@
 package handlers
 
 import (
+	"fmt"
+	"mime"
 	"net/http"
+	"strings"
 	"twitter-bookmarks-api/database"
+	"twitter-bookmarks-api/exporters"
 	"twitter-bookmarks-api/models"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
+// exportFlushEvery is how many bookmarks are written between flushes, so
+// that large exports reach the client while they are being read.
+const exportFlushEvery = 100
+
 func ExportBookmarks(c *gin.Context) {
 	userID := c.MustGet("userID").(uuid.UUID)
-
-	bookmarks, err := database.GetAllBookmarksByUserID(c.Request.Context(), userID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to fetch bookmarks"})
-		return
-	}
-
-	c.Header("Content-Disposition", "attachment; filename=bookmarks.json")
-	c.Header("Content-Type", "application/json")
-	c.JSON(http.StatusOK, gin.H{"bookmarks": bookmarks})
+	streamExport(c, userID, "bookmarks", nil, bookmarkFilterFromQuery(c))
 }
 
 func ExportCategory(c *gin.Context) {
@@ -37,16 +36,75 @@ func ExportCategory(c *gin.Context) {
 		return
 	}
 
-	bookmarks, err := database.GetBookmarksByCategoryID(c.Request.Context(), categoryID, userID)
+	filter := bookmarkFilterFromQuery(c)
+	filter.CategoryID = &categoryID
+	streamExport(c, userID, category.Name, category, filter)
+}
+
+// streamExport writes the bookmarks matching filter in the format picked by
+// ?format= (JSON by default) as they are read from the database. Tabular
+// formats write the columns listed in ?columns=. The response starts with
+// the first bookmark, so a query that fails up front still gets a 500; a
+// failure halfway through can only cut the download short.
+func streamExport(c *gin.Context, userID uuid.UUID, name string, category *models.Category, filter models.BookmarkFilter) {
+	format, ok := exporters.Get(c.DefaultQuery("format", "json"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid export format, expected one of " + strings.Join(exporters.Formats(), ", "),
+		})
+		return
+	}
+	columns, err := exporters.ParseColumns(c.Query("columns"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to fetch bookmarks"})
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid columns: " + err.Error()})
 		return
 	}
 
-	c.Header("Content-Disposition", "attachment; filename="+category.Name+".json")
-	c.Header("Content-Type", "application/json")
-	c.JSON(http.StatusOK, gin.H{
-		"category":  category,
-		"bookmarks": bookmarks,
+	exporter := format.New(c.Writer, exporters.Options{Category: category, Columns: columns})
+	started := false
+	start := func() {
+		started = true
+		disposition := mime.FormatMediaType("attachment", map[string]string{"filename": name + "." + format.Extension})
+		c.Header("Content-Disposition", disposition)
+		c.Header("Content-Type", format.ContentType)
+		c.Status(http.StatusOK)
+	}
+
+	stream := database.StreamBookmarks
+	if format.ByCategory {
+		stream = database.StreamBookmarksByCategory
+	}
+
+	written := 0
+	err = stream(c.Request.Context(), userID, filter, func(b *models.Bookmark) error {
+		if !started {
+			start()
+		}
+		if err := exporter.Write(b); err != nil {
+			return err
+		}
+		written++
+		if written%exportFlushEvery == 0 {
+			if err := exporter.Flush(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+		return nil
 	})
+	if err != nil {
+		if !started {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to fetch bookmarks"})
+			return
+		}
+		fmt.Printf("export stopped after %d bookmarks: %v\n", written, err)
+		return
+	}
+
+	if !started {
+		start()
+	}
+	if err := exporter.Close(); err != nil {
+		fmt.Printf("failed to finish export: %v\n", err)
+	}
 }
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"twitter-bookmarks-api/database"
+	"twitter-bookmarks-api/feeds"
+	"twitter-bookmarks-api/models"
+	"twitter-bookmarks-api/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// feedItemLimit is how many of the newest bookmarks a feed lists.
+const feedItemLimit = 50
+
+// GetFeeds lists the user's feeds. Their tokens are not stored, so the
+// listing has no URLs.
+func GetFeeds(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	list, err := database.GetFeeds(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to fetch feeds"})
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// CreateFeed creates a feed of all bookmarks, or of the category in the
+// optional category_id, and returns its URLs, which carry its token.
+func CreateFeed(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	var req models.CreateFeedRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+
+	if req.CategoryID != nil {
+		category, err := database.GetCategoryByID(c.Request.Context(), *req.CategoryID, userID)
+		if err != nil || category == nil {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Category not found"})
+			return
+		}
+	}
+
+	feed, err := services.CreateFeed(c.Request.Context(), userID, req.CategoryID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to create feed"})
+		return
+	}
+	feed.URLs = make(map[string]string)
+	for _, format := range feeds.Formats() {
+		feed.URLs[format] = feedURL(c, feed.Token, format)
+	}
+
+	c.JSON(http.StatusCreated, feed)
+}
+
+// DeleteFeed revokes a feed. Feed readers using it get 404 from then on.
+func DeleteFeed(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	feedID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid feed ID"})
+		return
+	}
+
+	err = database.DeleteFeed(c.Request.Context(), feedID, userID)
+	if errors.Is(err, database.ErrFeedNotFound) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Feed not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to revoke feed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Message: "Feed revoked"})
+}
+
+// GetFeed renders a feed for feed readers. The token in the URL takes the
+// place of the Authorization header they cannot send.
+func GetFeed(c *gin.Context) {
+	format, ok := feeds.Get(c.Param("format"))
+	if !ok {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Unknown feed format, expected one of " + strings.Join(feeds.Formats(), ", "),
+		})
+		return
+	}
+	ctx := c.Request.Context()
+
+	feed, err := services.FeedForToken(ctx, c.Param("token"))
+	if errors.Is(err, database.ErrFeedNotFound) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Feed not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to fetch feed"})
+		return
+	}
+
+	title := "Bookmarks"
+	if user, err := database.GetUserByID(ctx, feed.UserID); err == nil && user != nil {
+		title = "@" + user.Username + " bookmarks"
+	}
+	filter := models.BookmarkFilter{CategoryID: feed.CategoryID}
+	if feed.CategoryID != nil {
+		// A feed stops working while its category is in the trash.
+		category, err := database.GetCategoryByID(ctx, *feed.CategoryID, feed.UserID)
+		if err != nil || category == nil {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Feed not found"})
+			return
+		}
+		title += ": " + category.Name
+	}
+
+	page, err := database.GetBookmarksByUserID(ctx, feed.UserID, models.PaginationParams{Page: 1, PageSize: feedItemLimit}, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to fetch bookmarks"})
+		return
+	}
+
+	homeURL := os.Getenv("FRONTEND_URL")
+	if homeURL == "" {
+		homeURL = "http://localhost:5173"
+	}
+	var body bytes.Buffer
+	err = format.Render(&body, &feeds.Feed{
+		ID:        "urn:uuid:" + feed.ID.String(),
+		Title:     title,
+		HomeURL:   homeURL,
+		FeedURL:   feedURL(c, c.Param("token"), c.Param("format")),
+		Bookmarks: page.Bookmarks,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to render feed"})
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Data(http.StatusOK, format.ContentType, body.Bytes())
+}
+
+// feedURL is the URL of a feed in the given format, on BACKEND_URL or, if
+// that is not set, the host the request came to.
+func feedURL(c *gin.Context, token, format string) string {
+	base := strings.TrimSuffix(os.Getenv("BACKEND_URL"), "/")
+	if base == "" {
+		scheme := "http"
+		if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		base = scheme + "://" + c.Request.Host
+	}
+	return base + "/api/feeds/" + token + "/" + format
+}
@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strings"
+	"twitter-bookmarks-api/database"
+	"twitter-bookmarks-api/importers"
+	"twitter-bookmarks-api/models"
+	"twitter-bookmarks-api/services"
+	"twitter-bookmarks-api/xarchive"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	streamImportBatchSize  = 500
+	streamImportMaxLineLen = 1 << 20
+)
+
+// importSource returns fallback, or ImportSourceExtension for imports the
+// browser extension marks with ?source=extension.
+func importSource(c *gin.Context, fallback string) string {
+	if c.Query("source") == models.ImportSourceExtension {
+		return models.ImportSourceExtension
+	}
+	return fallback
+}
+
+// importLockFailed writes the response for an import that could not take the
+// user's import lock.
+func importLockFailed(c *gin.Context, err error) {
+	if errors.Is(err, database.ErrImportInProgress) {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Another import is in progress for this account"})
+		return
+	}
+	fmt.Printf("failed to start import: %v\n", err)
+	c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to start import"})
+}
+
+// runImport imports items with the mode from the query string, either right
+// away or, with ?async=true, as a background job. With ?dry_run=true it only
+// reports what the import would do, including AI suggestions when
+// ?ai_categories=true. It writes the response.
+func runImport(c *gin.Context, userID uuid.UUID, items []models.BookmarkImportItem, source string) {
+	mode := c.DefaultQuery("mode", models.ImportModeUpdate)
+	if !models.IsValidImportMode(mode) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid import mode, expected skip, update or replace"})
+		return
+	}
+
+	if dryRun := parseOptionalBool(c.Query("dry_run")); dryRun != nil && *dryRun {
+		aiCategories := parseOptionalBool(c.Query("ai_categories"))
+		preview, err := services.PreviewImport(c.Request.Context(), userID, items, mode, aiCategories != nil && *aiCategories)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to preview import"})
+			return
+		}
+		c.JSON(http.StatusOK, preview)
+		return
+	}
+
+	if async := parseOptionalBool(c.Query("async")); async != nil && *async {
+		job, err := services.EnqueueImportJob(c.Request.Context(), userID, mode, source, items)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to queue import"})
+			return
+		}
+		c.Header("Location", "/api/jobs/"+job.ID.String())
+		c.JSON(http.StatusAccepted, gin.H{"job_id": job.ID, "status": job.Status, "total": job.Total})
+		return
+	}
+
+	response, err := services.ImportBookmarks(c.Request.Context(), userID, items, mode, source)
+	if err != nil {
+		importLockFailed(c, err)
+		return
+	}
+	if response.OperationID != nil {
+		c.Header(operationHeader, response.OperationID.String())
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ImportBookmarksStream imports newline-delimited JSON bookmarks as they
+// arrive. Items are saved in batches of streamImportBatchSize and a progress
+// line is written back after each batch, so neither side has to hold the
+// whole import in memory. Result indexes are zero-based line numbers.
+func ImportBookmarksStream(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	if c.ContentType() != "application/x-ndjson" {
+		c.JSON(http.StatusUnsupportedMediaType, models.ErrorResponse{Error: "Content-Type must be application/x-ndjson"})
+		return
+	}
+
+	mode := c.DefaultQuery("mode", models.ImportModeUpdate)
+	if !models.IsValidImportMode(mode) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid import mode, expected skip, update or replace"})
+		return
+	}
+
+	// Progress is written while the body is still being read. Over HTTP/1,
+	// net/http would otherwise close the body at the first flush and the
+	// rest of the upload would be lost. HTTP/2 is always full duplex.
+	err := http.NewResponseController(c.Writer).EnableFullDuplex()
+	if err != nil && c.Request.ProtoMajor < 2 {
+		fmt.Printf("failed to enable full duplex for streamed import: %v\n", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Streamed imports are not supported by this server"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	session, err := services.NewImportSession(ctx, userID, mode, importSource(c, models.ImportSourceStream), false)
+	if err != nil {
+		importLockFailed(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	encoder := json.NewEncoder(c.Writer)
+
+	batch := 0
+	var items []models.BookmarkImportItem
+	var lines []int
+	var failures []models.ImportItemResult
+	writeProgress := func(progress models.ImportProgress) {
+		summary := session.Summary()
+		progress.Batch = batch
+		progress.Processed = session.Processed()
+		progress.ImportedCount = summary.ImportedCount
+		progress.UpdatedCount = summary.UpdatedCount
+		progress.DuplicateCount = summary.DuplicateCount
+		progress.FailedCount = summary.FailedCount
+		progress.AutoCategorized = summary.AutoCategorized
+		encoder.Encode(progress)
+		c.Writer.Flush()
+	}
+	flush := func() {
+		if len(items) == 0 && len(failures) == 0 {
+			return
+		}
+		batch++
+		results, _ := session.AddBatch(ctx, items)
+		for i := range results {
+			results[i].Index = lines[i]
+			if results[i].Outcome == models.ImportOutcomeFailed {
+				failures = append(failures, results[i])
+			}
+		}
+		writeProgress(models.ImportProgress{Errors: failures})
+		items, lines, failures = items[:0], lines[:0], nil
+	}
+
+	scanner := bufio.NewScanner(c.Request.Body)
+	scanner.Buffer(make([]byte, 64*1024), streamImportMaxLineLen)
+	line := -1
+	for scanner.Scan() && ctx.Err() == nil {
+		line++
+		raw := scanner.Bytes()
+		if len(raw) == 0 {
+			continue
+		}
+
+		var item models.BookmarkImportItem
+		if err := json.Unmarshal(raw, &item); err != nil {
+			session.AddFailure()
+			failures = append(failures, models.ImportItemResult{
+				Index:   line,
+				Outcome: models.ImportOutcomeFailed,
+				Error:   "invalid JSON",
+			})
+			continue
+		}
+
+		items = append(items, item)
+		lines = append(lines, line)
+		if len(items) >= streamImportBatchSize {
+			flush()
+		}
+	}
+	flush()
+
+	final := models.ImportProgress{Done: true}
+	switch err := scanner.Err(); {
+	case errors.Is(err, bufio.ErrTooLong):
+		final.Error = fmt.Sprintf("line at index %d exceeds %d bytes, import stopped", line+1, streamImportMaxLineLen)
+	case err != nil || ctx.Err() != nil:
+		final.Error = "failed to read request body, import stopped"
+	}
+	if final.Error != "" {
+		session.Interrupt()
+	}
+
+	// Record what was imported even if the client went away.
+	final.OperationID = session.Finish(context.WithoutCancel(ctx)).OperationID
+	writeProgress(final)
+}
+
+// maxArchiveUploadBytes caps X archive uploads. Archives include media, so
+// they can be large even though only a few small files are read; larger
+// archives can be imported by uploading their data/*.js files.
+const maxArchiveUploadBytes = 1 << 30
+
+// ImportXArchive imports the data archive X lets users download, uploaded as
+// the zip or as individual data/*.js files in the multipart field "file".
+// ?sources= picks which parts to import (bookmarks, tweets, likes; default
+// bookmarks and tweets); ?mode=, ?async= and ?dry_run= work as for ImportBookmarks.
+func ImportXArchive(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	sources := []string{xarchive.SourceBookmarks, xarchive.SourceTweets}
+	if raw := c.Query("sources"); raw != "" {
+		sources = strings.Split(raw, ",")
+		for _, source := range sources {
+			switch source {
+			case xarchive.SourceBookmarks, xarchive.SourceTweets, xarchive.SourceLikes:
+			default:
+				c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Unknown source " + source + ", expected bookmarks, tweets or likes"})
+				return
+			}
+		}
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxArchiveUploadBytes)
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid upload"})
+		return
+	}
+	files := form.File["file"]
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Upload the archive zip or its .js files as \"file\""})
+		return
+	}
+
+	archive := &xarchive.Archive{}
+	for _, header := range files {
+		if err := addArchiveUpload(archive, header); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: fmt.Sprintf("%s: %v", header.Filename, err)})
+			return
+		}
+	}
+
+	items := archive.Items(sources...)
+	if len(items) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "The upload contains no " + strings.Join(sources, " or ")})
+		return
+	}
+
+	runImport(c, userID, items, models.ImportSourceArchive)
+}
+
+func addArchiveUpload(archive *xarchive.Archive, header *multipart.FileHeader) error {
+	file, err := header.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if strings.EqualFold(path.Ext(header.Filename), ".zip") {
+		return archive.AddZip(file, header.Size)
+	}
+	if !xarchive.IsArchiveFile(header.Filename) {
+		return xarchive.ErrNotArchiveFile
+	}
+	return archive.AddReader(header.Filename, file)
+}
+
+// maxServiceExportBytes caps exports uploaded from other bookmark services.
+const maxServiceExportBytes = 100 << 20
+
+// ImportFromService imports an export from another bookmark service,
+// uploaded as multipart "file". Only links to tweets are imported; their
+// tags and folders become categories. ?mode= and ?dry_run= work as for
+// ImportBookmarks.
+func ImportFromService(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	format := c.Param("format")
+	importer, ok := importers.Get(format)
+	if !ok {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Unknown format, expected one of " + strings.Join(importers.Formats(), ", "),
+		})
+		return
+	}
+
+	mode := c.DefaultQuery("mode", models.ImportModeUpdate)
+	if !models.IsValidImportMode(mode) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid import mode, expected skip, update or replace"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxServiceExportBytes)
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Upload the export as \"file\""})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid upload"})
+		return
+	}
+	defer file.Close()
+
+	links, err := importer.Parse(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: fmt.Sprintf("Could not read %s export: %v", format, err)})
+		return
+	}
+	items, skipped := importers.Convert(links)
+	if skipped == nil {
+		skipped = []importers.Skipped{}
+	}
+
+	if dryRun := parseOptionalBool(c.Query("dry_run")); dryRun != nil && *dryRun {
+		aiCategories := parseOptionalBool(c.Query("ai_categories"))
+		preview, err := services.PreviewImport(c.Request.Context(), userID, items, mode, aiCategories != nil && *aiCategories)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to preview import"})
+			return
+		}
+		c.JSON(http.StatusOK, struct {
+			*models.ImportPreview
+			Format  string              `json:"format"`
+			Skipped []importers.Skipped `json:"skipped"`
+		}{preview, format, skipped})
+		return
+	}
+
+	response, err := services.ImportBookmarks(c.Request.Context(), userID, items, mode, models.ImportSourceService)
+	if err != nil {
+		importLockFailed(c, err)
+		return
+	}
+	if response.OperationID != nil {
+		c.Header(operationHeader, response.OperationID.String())
+	}
+
+	c.JSON(http.StatusOK, struct {
+		*models.ImportResponse
+		Format  string              `json:"format"`
+		Skipped []importers.Skipped `json:"skipped"`
+	}{response, format, skipped})
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"twitter-bookmarks-api/database"
+	"twitter-bookmarks-api/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// GetJob reports the status, progress and counts of a background import
+func GetJob(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid job ID"})
+		return
+	}
+
+	job, err := database.GetImportJob(c.Request.Context(), jobID, userID)
+	if errors.Is(err, database.ErrJobNotFound) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Job not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to fetch job"})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// CancelJob stops a background import. Queued jobs are cancelled at once;
+// running jobs stop after their current batch.
+func CancelJob(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid job ID"})
+		return
+	}
+
+	job, err := database.CancelImportJob(c.Request.Context(), jobID, userID)
+	switch {
+	case errors.Is(err, database.ErrJobNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Job not found"})
+		return
+	case errors.Is(err, database.ErrJobFinished):
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Job has already finished"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to cancel job"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Message: "Cancellation requested", Data: job})
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"twitter-bookmarks-api/database"
+	"twitter-bookmarks-api/models"
+	"twitter-bookmarks-api/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// operationHeader carries the id of the operation a mutating request was
+// recorded as, so the frontend can offer to undo it.
+const operationHeader = "X-Operation-ID"
+
+// recordOperation logs a completed mutation for undo and sets the operation
+// header. A failure to record is logged but does not fail the request, since
+// the mutation itself already succeeded. Bookmark edits and deletes record
+// their operation in the mutation's own transaction instead.
+func recordOperation(c *gin.Context, userID uuid.UUID, kind string, inverse models.OperationInverse) {
+	id, err := database.RecordOperation(c.Request.Context(), userID, kind, inverse)
+	if err != nil {
+		fmt.Printf("failed to record %s operation: %v\n", kind, err)
+		return
+	}
+	c.Header(operationHeader, id.String())
+}
+
+// UndoOperation reverts a recorded operation if it is still within the undo window
+func UndoOperation(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	operationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid operation ID"})
+		return
+	}
+
+	op, err := database.UndoOperation(c.Request.Context(), operationID, userID, services.UndoWindow())
+	switch {
+	case errors.Is(err, database.ErrOperationNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Operation not found"})
+		return
+	case errors.Is(err, database.ErrOperationUndone):
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Operation has already been undone"})
+		return
+	case errors.Is(err, database.ErrOperationExpired):
+		c.JSON(http.StatusGone, models.ErrorResponse{Error: "Operation can no longer be undone"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to undo operation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Message: "Operation undone", Data: op})
+}
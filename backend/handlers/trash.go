@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"twitter-bookmarks-api/database"
+	"twitter-bookmarks-api/models"
+	"twitter-bookmarks-api/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func GetTrash(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	bookmarks, err := database.GetTrashedBookmarks(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to fetch trash"})
+		return
+	}
+
+	categories, err := database.GetTrashedCategories(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to fetch trash"})
+		return
+	}
+
+	if bookmarks == nil {
+		bookmarks = []models.Bookmark{}
+	}
+	if categories == nil {
+		categories = []models.Category{}
+	}
+
+	c.JSON(http.StatusOK, models.TrashResponse{
+		Bookmarks:     bookmarks,
+		Categories:    categories,
+		RetentionDays: services.TrashRetentionDays(),
+	})
+}
+
+// RestoreFromTrash restores a trashed bookmark or category by id
+func RestoreFromTrash(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid ID"})
+		return
+	}
+
+	kind, err := database.RestoreFromTrash(c.Request.Context(), id, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Item not found in trash"})
+		return
+	}
+
+	inverse := models.OperationInverse{TrashBookmarks: []uuid.UUID{id}}
+	if kind == "category" {
+		inverse = models.OperationInverse{TrashCategories: []uuid.UUID{id}}
+	}
+	recordOperation(c, userID, models.OperationRestore, inverse)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Restored successfully",
+		Data:    map[string]string{"type": kind, "id": id.String()},
+	})
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"twitter-bookmarks-api/database"
+	"twitter-bookmarks-api/models"
+	"twitter-bookmarks-api/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// GetXSync reports the state of the user's bookmark sync from the X API
+func GetXSync(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	sync, err := database.GetXSync(c.Request.Context(), userID)
+	if errors.Is(err, database.ErrXNotConnected) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "X account not connected, sign in with X again"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to fetch sync state"})
+		return
+	}
+
+	c.JSON(http.StatusOK, sync)
+}
+
+// SyncX schedules a bookmark sync from the X API right away. It runs in the
+// background; GET /api/sync/x reports its progress.
+func SyncX(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	if !services.XSyncEnabled() {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: "X sync is not enabled on this server"})
+		return
+	}
+
+	sync, err := services.RequestXSync(c.Request.Context(), userID)
+	if errors.Is(err, database.ErrXNotConnected) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "X account not connected, sign in with X again"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to schedule sync"})
+		return
+	}
+
+	c.Header("Location", "/api/sync/x")
+	c.JSON(http.StatusAccepted, models.SuccessResponse{Message: "Sync scheduled", Data: sync})
+}
@@ -0,0 +1,98 @@
+package importers
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pocketCSVImporter reads Pocket's CSV export, with the columns title, url,
+// time_added (Unix seconds) and tags separated by "|".
+type pocketCSVImporter struct{}
+
+func (pocketCSVImporter) Parse(r io.Reader) ([]Link, error) {
+	return parseCSV(r, func(row csvRow) Link {
+		link := Link{URL: row.get("url"), Title: row.get("title"), Tags: splitTags(row.get("tags"), "|")}
+		if seconds, err := strconv.ParseInt(row.get("time_added"), 10, 64); err == nil && seconds > 0 {
+			link.AddedAt = time.Unix(seconds, 0)
+		}
+		return link
+	})
+}
+
+// raindropCSVImporter reads Raindrop.io's CSV export, with the columns
+// title, excerpt, url, folder, tags separated by commas and created as an
+// ISO 8601 timestamp. The folder becomes a tag.
+type raindropCSVImporter struct{}
+
+func (raindropCSVImporter) Parse(r io.Reader) ([]Link, error) {
+	return parseCSV(r, func(row csvRow) Link {
+		link := Link{
+			URL:         row.get("url"),
+			Title:       row.get("title"),
+			Description: row.get("excerpt"),
+			Tags:        splitTags(row.get("tags"), ","),
+		}
+		if folder := row.get("folder"); folder != "" && !strings.EqualFold(folder, "Unsorted") {
+			link.Tags = append(link.Tags, folder)
+		}
+		if created, err := time.Parse(time.RFC3339, row.get("created")); err == nil {
+			link.AddedAt = created
+		}
+		return link
+	})
+}
+
+type csvRow struct {
+	columns map[string]int
+	record  []string
+}
+
+func (r csvRow) get(column string) string {
+	i, ok := r.columns[column]
+	if !ok || i >= len(r.record) {
+		return ""
+	}
+	return strings.TrimSpace(r.record[i])
+}
+
+// parseCSV reads a CSV file with a header row, looking columns up by name.
+func parseCSV(r io.Reader, convert func(csvRow) Link) ([]Link, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.TrimPrefix(name, "\ufeff")
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["url"]; !ok {
+		return nil, errors.New("missing url column")
+	}
+
+	var links []Link
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if link := convert(csvRow{columns: columns, record: record}); link.URL != "" {
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
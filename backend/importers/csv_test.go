@@ -0,0 +1,102 @@
+package importers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPocketCSVImporter(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []Link
+		wantErr bool
+	}{
+		{
+			name: "links with tags",
+			input: "title,url,time_added,tags,status\n" +
+				"A tweet,https://x.com/jack/status/20,1142974214,go|news,unread\n" +
+				"No tags,https://example.com/,0,,archive\n",
+			want: []Link{
+				{URL: "https://x.com/jack/status/20", Title: "A tweet", Tags: []string{"go", "news"}, AddedAt: time.Unix(1142974214, 0)},
+				{URL: "https://example.com/", Title: "No tags"},
+			},
+		},
+		{
+			name:  "byte order mark and reordered columns",
+			input: "\ufeffurl,title\nhttps://x.com/i/status/1,First\n",
+			want:  []Link{{URL: "https://x.com/i/status/1", Title: "First"}},
+		},
+		{
+			name:  "rows without url are dropped",
+			input: "title,url\nEmpty,\nShort row\n",
+			want:  nil,
+		},
+		{
+			name:  "empty file",
+			input: "",
+			want:  nil,
+		},
+		{
+			name:    "missing url column",
+			input:   "title,link\nA,https://x.com/jack/status/20\n",
+			wantErr: true,
+		},
+		{
+			name:    "malformed csv",
+			input:   "title,url\n\"unterminated,https://x.com/jack/status/20\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pocketCSVImporter{}.Parse(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRaindropCSVImporter(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Link
+	}{
+		{
+			name: "folder becomes a tag",
+			input: "id,title,note,excerpt,url,folder,tags,created\n" +
+				`1,Title,,The excerpt,https://twitter.com/jack/status/20,Reading,"go, db",2024-05-01T10:00:00Z` + "\n",
+			want: []Link{{
+				URL:         "https://twitter.com/jack/status/20",
+				Title:       "Title",
+				Description: "The excerpt",
+				Tags:        []string{"go", "db", "Reading"},
+				AddedAt:     time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+			}},
+		},
+		{
+			name: "unsorted folder and bad date are ignored",
+			input: "title,url,folder,created\n" +
+				"T,https://x.com/i/status/5,Unsorted,yesterday\n",
+			want: []Link{{URL: "https://x.com/i/status/5", Title: "T"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := raindropCSVImporter{}.Parse(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
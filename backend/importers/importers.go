@@ -0,0 +1,130 @@
+// Package importers reads bookmark exports from other services. Each format
+// has an Importer that turns the export into links; links to tweets become
+// import items and their tags and folders become category names. Links to
+// anything other than a tweet are reported as skipped.
+package importers
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"twitter-bookmarks-api/models"
+	"twitter-bookmarks-api/tweeturl"
+)
+
+// Importer parses an export file of one format.
+type Importer interface {
+	Parse(r io.Reader) ([]Link, error)
+}
+
+// Link is a bookmark as another service stores it.
+type Link struct {
+	URL   string
+	Title string
+	// Description is the longer text some services keep next to the title,
+	// such as Pinboard's extended description or a Raindrop excerpt.
+	Description string
+	Tags        []string
+	AddedAt     time.Time
+}
+
+// Skipped is a link that was not imported.
+type Skipped struct {
+	URL    string `json:"url"`
+	Reason string `json:"reason"`
+}
+
+var importers = map[string]Importer{
+	"netscape":      netscapeImporter{},
+	"pocket-html":   netscapeImporter{},
+	"pocket-csv":    pocketCSVImporter{},
+	"raindrop-csv":  raindropCSVImporter{},
+	"pinboard-json": pinboardJSONImporter{},
+}
+
+// Get returns the importer for format.
+func Get(format string) (Importer, bool) {
+	importer, ok := importers[format]
+	return importer, ok
+}
+
+// Formats lists the supported formats.
+func Formats() []string {
+	formats := make([]string, 0, len(importers))
+	for format := range importers {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// Convert splits links into import items for tweets, with their tags as
+// categories, and skipped links. A tweet saved more than once keeps its
+// first occurrence, with the tags of all of them.
+func Convert(links []Link) ([]models.BookmarkImportItem, []Skipped) {
+	var items []models.BookmarkImportItem
+	var skipped []Skipped
+	byTweet := make(map[string]int)
+
+	for _, link := range links {
+		tweetID, username, ok := tweeturl.Parse(link.URL)
+		if !ok {
+			skipped = append(skipped, Skipped{URL: link.URL, Reason: "not a link to a tweet"})
+			continue
+		}
+
+		if i, seen := byTweet[tweetID]; seen {
+			items[i].Categories = mergeNames(items[i].Categories, link.Tags)
+			continue
+		}
+
+		item := models.BookmarkImportItem{
+			TweetID:        tweetID,
+			TweetText:      strings.TrimSpace(link.Description),
+			AuthorUsername: username,
+			TweetURL:       tweeturl.Format(username, tweetID),
+		}
+		if item.TweetText == "" {
+			item.TweetText = strings.TrimSpace(link.Title)
+		}
+		if !link.AddedAt.IsZero() {
+			item.BookmarkedAt = link.AddedAt.UTC().Format(time.RFC3339)
+		}
+
+		item.Categories = mergeNames(nil, link.Tags)
+
+		byTweet[tweetID] = len(items)
+		items = append(items, item)
+	}
+	return items, skipped
+}
+
+// mergeNames adds the non-empty names in add to names, ignoring case
+// duplicates.
+func mergeNames(names, add []string) []string {
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		seen[strings.ToLower(name)] = true
+	}
+	for _, name := range add {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// splitTags splits a tag list on sep, dropping empty tags.
+func splitTags(raw, sep string) []string {
+	var tags []string
+	for _, tag := range strings.Split(raw, sep) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
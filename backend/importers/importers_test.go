@@ -0,0 +1,71 @@
+package importers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	"twitter-bookmarks-api/models"
+)
+
+func TestConvert(t *testing.T) {
+	added := time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+
+	tests := []struct {
+		name        string
+		links       []Link
+		wantItems   []models.BookmarkImportItem
+		wantSkipped []Skipped
+	}{
+		{
+			name: "tweet with description and tags",
+			links: []Link{{
+				URL: "https://twitter.com/jack/status/20?s=20", Title: "Title", Description: " Text ",
+				Tags: []string{"Go", " ", "news"}, AddedAt: added,
+			}},
+			wantItems: []models.BookmarkImportItem{{
+				TweetID: "20", TweetText: "Text", AuthorUsername: "jack", TweetURL: "https://x.com/jack/status/20",
+				BookmarkedAt: "2024-05-01T10:00:00Z", Categories: []string{"Go", "news"},
+			}},
+		},
+		{
+			name:  "title is used without description",
+			links: []Link{{URL: "https://x.com/i/web/status/21", Title: "Only a title"}},
+			wantItems: []models.BookmarkImportItem{{
+				TweetID: "21", TweetText: "Only a title", TweetURL: "https://x.com/i/status/21",
+			}},
+		},
+		{
+			name: "repeated tweet keeps the first and merges tags",
+			links: []Link{
+				{URL: "https://x.com/jack/status/22", Title: "First", Tags: []string{"go"}},
+				{URL: "https://mobile.twitter.com/jack/status/22", Title: "Second", Tags: []string{"GO", "db"}},
+			},
+			wantItems: []models.BookmarkImportItem{{
+				TweetID: "22", TweetText: "First", AuthorUsername: "jack", TweetURL: "https://x.com/jack/status/22",
+				Categories: []string{"go", "db"},
+			}},
+		},
+		{
+			name: "other links are skipped",
+			links: []Link{
+				{URL: "https://example.com/jack/status/23"},
+				{URL: "https://x.com/jack"},
+			},
+			wantSkipped: []Skipped{
+				{URL: "https://example.com/jack/status/23", Reason: "not a link to a tweet"},
+				{URL: "https://x.com/jack", Reason: "not a link to a tweet"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, skipped := Convert(tt.links)
+			if !reflect.DeepEqual(items, tt.wantItems) {
+				t.Errorf("Convert() items = %+v, want %+v", items, tt.wantItems)
+			}
+			if !reflect.DeepEqual(skipped, tt.wantSkipped) {
+				t.Errorf("Convert() skipped = %+v, want %+v", skipped, tt.wantSkipped)
+			}
+		})
+	}
+}
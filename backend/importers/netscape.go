@@ -0,0 +1,96 @@
+package importers
+
+import (
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// netscapeImporter reads the Netscape bookmark file format browsers export,
+// and Pocket's HTML export, which uses the same <a> tags with time_added and
+// tags attributes. Browser folders become tags.
+type netscapeImporter struct{}
+
+var (
+	htmlTag       = regexp.MustCompile(`(?is)<(/?)([a-z0-9]+)((?:\s+[^>]*)?)>`)
+	htmlAttribute = regexp.MustCompile(`(?is)([a-z_:-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+)
+
+func (netscapeImporter) Parse(r io.Reader) ([]Link, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	doc := string(data)
+
+	var links []Link
+	var folders []string
+	pendingFolder := ""
+
+	tags := htmlTag.FindAllStringSubmatchIndex(doc, -1)
+	for i, m := range tags {
+		closing := doc[m[2]:m[3]] == "/"
+		name := strings.ToLower(doc[m[4]:m[5]])
+		attrs := doc[m[6]:m[7]]
+
+		// text runs from the end of this tag to the start of the next one.
+		text := ""
+		if i+1 < len(tags) {
+			text = doc[m[1]:tags[i+1][0]]
+		} else {
+			text = doc[m[1]:]
+		}
+		text = strings.TrimSpace(html.UnescapeString(text))
+
+		switch {
+		case name == "h3" && !closing:
+			pendingFolder = text
+			// The browser toolbar is a folder in the file but not a topic.
+			if _, toolbar := parseAttributes(attrs)["personal_toolbar_folder"]; toolbar {
+				pendingFolder = ""
+			}
+		case name == "dl" && !closing:
+			folders = append(folders, pendingFolder)
+			pendingFolder = ""
+		case name == "dl" && closing && len(folders) > 0:
+			folders = folders[:len(folders)-1]
+		case name == "a" && !closing:
+			link := linkFromAttributes(parseAttributes(attrs))
+			link.Title = text
+			for _, folder := range folders {
+				if folder != "" {
+					link.Tags = append(link.Tags, folder)
+				}
+			}
+			if link.URL != "" {
+				links = append(links, link)
+			}
+		case name == "dd" && !closing && len(links) > 0:
+			links[len(links)-1].Description = text
+		}
+	}
+	return links, nil
+}
+
+func parseAttributes(raw string) map[string]string {
+	attrs := make(map[string]string)
+	for _, m := range htmlAttribute.FindAllStringSubmatch(raw, -1) {
+		value := m[2] + m[3] + m[4]
+		attrs[strings.ToLower(m[1])] = html.UnescapeString(value)
+	}
+	return attrs
+}
+
+func linkFromAttributes(attrs map[string]string) Link {
+	link := Link{URL: attrs["href"], Tags: splitTags(attrs["tags"], ",")}
+	for _, key := range []string{"add_date", "time_added"} {
+		if seconds, err := strconv.ParseInt(attrs[key], 10, 64); err == nil && seconds > 0 {
+			link.AddedAt = time.Unix(seconds, 0)
+			break
+		}
+	}
+	return link
+}
@@ -0,0 +1,69 @@
+package importers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNetscapeImporter(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Link
+	}{
+		{
+			name: "nested folders",
+			input: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<DL><p>
+  <DT><H3 PERSONAL_TOOLBAR_FOLDER="true">Bookmarks bar</H3>
+  <DL><p>
+    <DT><H3>Tech</H3>
+    <DL><p>
+      <DT><A HREF="https://x.com/jack/status/20" ADD_DATE="1142974214">just setting up &amp; more</A>
+      <DD>A longer note
+    </DL><p>
+    <DT><A HREF='https://example.com/'>Example</A>
+  </DL><p>
+</DL><p>`,
+			want: []Link{
+				{URL: "https://x.com/jack/status/20", Title: "just setting up & more", Description: "A longer note",
+					Tags: []string{"Tech"}, AddedAt: time.Unix(1142974214, 0)},
+				{URL: "https://example.com/", Title: "Example"},
+			},
+		},
+		{
+			name: "pocket export",
+			input: `<ul>
+<li><a href="https://twitter.com/i/web/status/7" time_added="1700000000" tags="go,news">Tweet</a></li>
+<li><a href=https://x.com/a/status/8>Unquoted</a></li>
+</ul>`,
+			want: []Link{
+				{URL: "https://twitter.com/i/web/status/7", Title: "Tweet", Tags: []string{"go", "news"}, AddedAt: time.Unix(1700000000, 0)},
+				{URL: "https://x.com/a/status/8", Title: "Unquoted"},
+			},
+		},
+		{
+			name:  "anchors without href are dropped",
+			input: `<DL><DT><A NAME="top">Top</A></DL>`,
+			want:  nil,
+		},
+		{
+			name:  "empty file",
+			input: "",
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := netscapeImporter{}.Parse(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
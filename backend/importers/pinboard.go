@@ -0,0 +1,41 @@
+package importers
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+)
+
+// pinboardJSONImporter reads Pinboard's JSON export. Pinboard calls the
+// title "description" and the longer text "extended"; tags are separated by
+// spaces.
+type pinboardJSONImporter struct{}
+
+func (pinboardJSONImporter) Parse(r io.Reader) ([]Link, error) {
+	var posts []struct {
+		Href        string `json:"href"`
+		Description string `json:"description"`
+		Extended    string `json:"extended"`
+		Time        string `json:"time"`
+		Tags        string `json:"tags"`
+	}
+	if err := json.NewDecoder(r).Decode(&posts); err != nil {
+		return nil, err
+	}
+
+	links := make([]Link, 0, len(posts))
+	for _, post := range posts {
+		link := Link{
+			URL:         post.Href,
+			Title:       post.Description,
+			Description: post.Extended,
+			Tags:        strings.Fields(post.Tags),
+		}
+		if added, err := time.Parse(time.RFC3339, post.Time); err == nil {
+			link.AddedAt = added
+		}
+		links = append(links, link)
+	}
+	return links, nil
+}
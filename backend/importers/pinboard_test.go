@@ -0,0 +1,57 @@
+package importers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPinboardJSONImporter(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []Link
+		wantErr bool
+	}{
+		{
+			name: "posts",
+			input: `[
+				{"href": "https://x.com/jack/status/20", "description": "Title", "extended": "Longer text",
+				 "time": "2024-05-01T10:00:00Z", "tags": "go  news"},
+				{"href": "https://example.com/", "description": "", "extended": "", "time": "", "tags": ""}
+			]`,
+			want: []Link{
+				{URL: "https://x.com/jack/status/20", Title: "Title", Description: "Longer text",
+					Tags: []string{"go", "news"}, AddedAt: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)},
+				{URL: "https://example.com/", Tags: []string{}},
+			},
+		},
+		{
+			name:  "empty export",
+			input: `[]`,
+			want:  []Link{},
+		},
+		{
+			name:    "not an array",
+			input:   `{"href": "https://x.com/jack/status/20"}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid json",
+			input:   `[{"href": }]`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pinboardJSONImporter{}.Parse(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+// Package langdetect guesses the language of short texts such as tweets.
+// It only distinguishes the languages we have Postgres text-search
+// configurations for and works entirely offline using stopword and
+// diacritic frequencies.
+package langdetect
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const (
+	English    = "en"
+	Spanish    = "es"
+	Portuguese = "pt"
+	// Undetermined is returned when the text is too short or too ambiguous to call.
+	Undetermined = "und"
+)
+
+// minScore is the smallest score a language needs before we trust the guess.
+const minScore = 2
+
+// searchConfigs maps language codes to Postgres text-search configurations.
+var searchConfigs = map[string]string{
+	English:    "english",
+	Spanish:    "spanish",
+	Portuguese: "portuguese",
+}
+
+// stopwords holds common function words per language. Words shared between
+// languages (de, que, para, como, este, do, as, ...) are deliberately left
+// out so that they do not blur the distinction.
+var stopwords = map[string]map[string]bool{
+	English: wordSet(`the and of to is in that it for you with on this are was be have
+		not but they at what so if my we your from just can all will about an or
+		there their has been would when who more how like one don't it's i'm`),
+	Spanish: wordSet(`el la los las y es en un una con del al lo pero más esto muy
+		también hay son fue tiene yo mi sus le les ya cuando hasta están eso
+		qué cómo`),
+	Portuguese: wordSet(`os um uma em da dos das não com mais mas muito também isso
+		esse essa ele ela eles você é são foi tem ao pelo pela meu minha seu sua
+		quando sem até tudo estão então já`),
+}
+
+// markers are characters that occur in one language and not the others.
+var markers = map[string]string{
+	Spanish:    "ñ¿¡",
+	Portuguese: "ãõç",
+}
+
+var noisePattern = regexp.MustCompile(`https?://\S+|[@#]\w+`)
+
+// Detect returns the language code of text: English, Spanish, Portuguese or
+// Undetermined.
+func Detect(text string) string {
+	text = strings.ToLower(noisePattern.ReplaceAllString(text, " "))
+
+	scores := make(map[string]int, len(stopwords))
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	for _, word := range words {
+		for lang, set := range stopwords {
+			if set[word] {
+				scores[lang]++
+			}
+		}
+	}
+	for lang, chars := range markers {
+		for _, r := range text {
+			if strings.ContainsRune(chars, r) {
+				scores[lang] += 2
+			}
+		}
+	}
+
+	best, bestScore, runnerUp := Undetermined, 0, 0
+	for _, lang := range []string{English, Spanish, Portuguese} {
+		switch score := scores[lang]; {
+		case score > bestScore:
+			best, bestScore, runnerUp = lang, score, bestScore
+		case score > runnerUp:
+			runnerUp = score
+		}
+	}
+
+	if bestScore < minScore || bestScore == runnerUp {
+		return Undetermined
+	}
+	return best
+}
+
+// SearchConfig returns the Postgres text-search configuration for lang,
+// falling back to "simple" for undetermined or unsupported languages.
+func SearchConfig(lang string) string {
+	if config, ok := searchConfigs[lang]; ok {
+		return config
+	}
+	return "simple"
+}
+
+// IsSupported reports whether lang is a code Detect can return.
+func IsSupported(lang string) bool {
+	_, ok := searchConfigs[lang]
+	return ok || lang == Undetermined
+}
+
+func wordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
@@ -0,0 +1,47 @@
+package langdetect
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"english", "This is what you get when the build is green and it works", English},
+		{"spanish", "El perro y los gatos están en la casa, pero muy tranquilos", Spanish},
+		{"portuguese", "Você não sabe como isso é muito importante para mim", Portuguese},
+		{"spanish marker", "¿Mañana?", Spanish},
+		{"portuguese marker", "Atenção", Portuguese},
+		{"noise is ignored", "@the @and #the #with https://the.and/with/the ok", Undetermined},
+		{"too short", "the", Undetermined},
+		{"tie", "the and el la", Undetermined},
+		{"no words", "12345 !!!", Undetermined},
+		{"empty", "", Undetermined},
+		{"apostrophes", "I'm sure it's fine", English},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Detect(tt.text); got != tt.want {
+				t.Errorf("Detect(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchConfig(t *testing.T) {
+	tests := []struct {
+		lang, want string
+	}{
+		{English, "english"},
+		{Spanish, "spanish"},
+		{Portuguese, "portuguese"},
+		{Undetermined, "simple"},
+		{"fr", "simple"},
+	}
+	for _, tt := range tests {
+		if got := SearchConfig(tt.lang); got != tt.want {
+			t.Errorf("SearchConfig(%q) = %q, want %q", tt.lang, got, tt.want)
+		}
+	}
+}
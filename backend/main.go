@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -13,6 +14,7 @@ import (
 	"twitter-bookmarks-api/database"
 	"twitter-bookmarks-api/handlers"
 	"twitter-bookmarks-api/middleware"
+	"twitter-bookmarks-api/services"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -29,7 +31,24 @@ func main() {
 
 	auth.InitOAuth()
 
-	router := gin.Default()
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	services.StartStatusChecker(workerCtx)
+	services.StartTrashPurger(workerCtx)
+	services.StartImportWorker(workerCtx)
+	switch err := auth.CheckTokenKey(); {
+	case errors.Is(err, auth.ErrTokenKeyNotSet):
+		fmt.Println("X_TOKEN_ENCRYPTION_KEY not set, X bookmark sync is disabled")
+	case err != nil:
+		log.Fatalf("Invalid X token encryption key: %v", err)
+	default:
+		services.StartXSyncWorker(workerCtx)
+	}
+
+	// gin.Default's logger would print feed tokens from request paths;
+	// middleware.Logger redacts them.
+	router := gin.New()
+	router.Use(gin.Recovery())
 
 	corsConfig := cors.DefaultConfig()
 	frontendURL := os.Getenv("FRONTEND_URL")
@@ -50,7 +69,8 @@ func main() {
 		return false
 	}
 	corsConfig.AllowCredentials = true
-	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization"}
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "Idempotency-Key"}
+	corsConfig.ExposeHeaders = []string{"X-Operation-ID", "Location", "Idempotent-Replayed"}
 	router.Use(cors.New(corsConfig))
 
 	router.Use(middleware.Logger())
@@ -69,9 +89,18 @@ func main() {
 		bookmarksGroup.Use(middleware.AuthMiddleware())
 		{
 			bookmarksGroup.GET("", handlers.GetBookmarks)
-			bookmarksGroup.POST("/import", handlers.ImportBookmarks)
+			bookmarksGroup.POST("/import", middleware.Idempotency(), handlers.ImportBookmarks)
+			bookmarksGroup.POST("/import/stream", middleware.StreamIdempotency(), handlers.ImportBookmarksStream)
+			bookmarksGroup.POST("/import/archive", handlers.ImportXArchive)
+			bookmarksGroup.GET("/sync-state", handlers.GetSyncState)
+			bookmarksGroup.POST("/bulk", handlers.BulkBookmarks)
+			bookmarksGroup.PATCH("/:id", handlers.UpdateBookmark)
 			bookmarksGroup.DELETE("/:id", handlers.DeleteBookmark)
+			bookmarksGroup.GET("/:id/revisions", handlers.GetBookmarkRevisions)
 			bookmarksGroup.GET("/search", handlers.SearchBookmarks)
+			bookmarksGroup.GET("/dead", handlers.GetDeadBookmarks)
+			bookmarksGroup.GET("/duplicates", handlers.GetDuplicates)
+			bookmarksGroup.POST("/duplicates/merge", handlers.MergeDuplicates)
 			bookmarksGroup.POST("/:id/category", handlers.AssignCategory)
 			bookmarksGroup.DELETE("/:id/category/:categoryId", handlers.RemoveCategory)
 		}
@@ -85,6 +114,39 @@ func main() {
 			categoriesGroup.DELETE("/:id", handlers.DeleteCategory)
 		}
 
+		importGroup := api.Group("/import")
+		importGroup.Use(middleware.AuthMiddleware())
+		{
+			importGroup.POST("/:format", handlers.ImportFromService)
+		}
+
+		syncGroup := api.Group("/sync")
+		syncGroup.Use(middleware.AuthMiddleware())
+		{
+			syncGroup.GET("/x", handlers.GetXSync)
+			syncGroup.POST("/x", handlers.SyncX)
+		}
+
+		trashGroup := api.Group("/trash")
+		trashGroup.Use(middleware.AuthMiddleware())
+		{
+			trashGroup.GET("", handlers.GetTrash)
+			trashGroup.POST("/:id/restore", handlers.RestoreFromTrash)
+		}
+
+		operationsGroup := api.Group("/operations")
+		operationsGroup.Use(middleware.AuthMiddleware())
+		{
+			operationsGroup.POST("/:id/undo", handlers.UndoOperation)
+		}
+
+		jobsGroup := api.Group("/jobs")
+		jobsGroup.Use(middleware.AuthMiddleware())
+		{
+			jobsGroup.GET("/:id", handlers.GetJob)
+			jobsGroup.POST("/:id/cancel", handlers.CancelJob)
+		}
+
 		exportGroup := api.Group("/export")
 		exportGroup.Use(middleware.AuthMiddleware())
 		{
@@ -92,6 +154,17 @@ func main() {
 			exportGroup.GET("/category/:id", handlers.ExportCategory)
 		}
 
+		feedsGroup := api.Group("/feeds")
+		feedsGroup.Use(middleware.AuthMiddleware())
+		{
+			feedsGroup.GET("", handlers.GetFeeds)
+			feedsGroup.POST("", handlers.CreateFeed)
+			feedsGroup.DELETE("/:id", handlers.DeleteFeed)
+		}
+		// Feed readers can't send an Authorization header; the token in
+		// the URL authenticates them.
+		api.GET("/feeds/:token/:format", handlers.GetFeed)
+
 		userGroup := api.Group("/user")
 		userGroup.Use(middleware.AuthMiddleware())
 		{
@@ -133,6 +206,7 @@ func main() {
 	<-quit
 
 	fmt.Println("Shutting down server...")
+	stopWorkers()
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -0,0 +1,203 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+	"twitter-bookmarks-api/database"
+	"twitter-bookmarks-api/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	maxIdempotencyKeyLen = 255
+	// idempotencyAbandonAfter is when a reservation without a response is
+	// assumed to belong to a request that died with the server.
+	idempotencyAbandonAfter = time.Hour
+)
+
+// replayedHeaders are the response headers stored with a response and sent
+// again on replay.
+var replayedHeaders = []string{"Content-Type", "Location", "X-Operation-ID"}
+
+// responseRecorder keeps a copy of everything written to the response, or
+// with lastLineOnly only its last line, which for a streamed import is the
+// final summary.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body         bytes.Buffer
+	lastLineOnly bool
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.record(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.record([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *responseRecorder) record(b []byte) {
+	w.body.Write(b)
+	if !w.lastLineOnly {
+		return
+	}
+	if i := bytes.LastIndexByte(bytes.TrimSuffix(w.body.Bytes(), []byte("\n")), '\n'); i >= 0 {
+		w.body.Next(i + 1)
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, which
+// streamed handlers need to enable full duplex.
+func (w *responseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Idempotency makes requests with an Idempotency-Key header safe to retry.
+// The first request with a key runs and its response is stored for
+// IDEMPOTENCY_KEY_TTL; retries with the same key and body get the stored
+// response back with an Idempotent-Replayed header instead of running again.
+// Reusing a key for a different request is rejected, as is a retry while
+// the first request is still running. Server errors are not stored, so the
+// request can be retried. Must run after AuthMiddleware.
+func Idempotency() gin.HandlerFunc {
+	return idempotency(false)
+}
+
+// StreamIdempotency is Idempotency for handlers that read their body as it
+// arrives, such as streamed imports. The body is not buffered: it is hashed
+// while the handler reads it, and a retry's body is hashed and compared
+// before the stored response is replayed. Only the last line of the
+// response is stored and replayed. A response whose client went away before
+// it finished is not stored, so that the interrupted request can be retried.
+func StreamIdempotency() gin.HandlerFunc {
+	return idempotency(true)
+}
+
+func idempotency(streamed bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLen)})
+			c.Abort()
+			return
+		}
+		userID := c.MustGet("userID").(uuid.UUID)
+
+		hash := sha256.New()
+		fmt.Fprintf(hash, "%s %s\n", c.Request.Method, c.Request.URL.RequestURI())
+		if !streamed {
+			body, err := io.ReadAll(c.Request.Body)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+				c.Abort()
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			hash.Write(body)
+		}
+		requestHash := hex.EncodeToString(hash.Sum(nil))
+
+		now := time.Now()
+		stored, err := database.ReserveIdempotencyKey(c.Request.Context(), userID, key, requestHash,
+			now.Add(-services.IdempotencyKeyTTL()), now.Add(-idempotencyAbandonAfter))
+		switch {
+		case errors.Is(err, database.ErrIdempotencyKeyInProgress):
+			c.JSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still in progress"})
+			c.Abort()
+			return
+		case errors.Is(err, database.ErrIdempotencyKeyReused):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used for a different request"})
+			c.Abort()
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check Idempotency-Key"})
+			c.Abort()
+			return
+		case stored != nil:
+			if streamed && stored.BodyHash != "" {
+				bodyHash := sha256.New()
+				if _, err := io.Copy(bodyHash, c.Request.Body); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+					c.Abort()
+					return
+				}
+				if hex.EncodeToString(bodyHash.Sum(nil)) != stored.BodyHash {
+					c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used for a different request"})
+					c.Abort()
+					return
+				}
+			}
+			for name, value := range stored.Headers {
+				c.Header(name, value)
+			}
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(stored.StatusCode, stored.Headers["Content-Type"], stored.Body)
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer, lastLineOnly: streamed}
+		c.Writer = recorder
+		bodyHash := sha256.New()
+		if streamed {
+			body := c.Request.Body
+			c.Request.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.TeeReader(body, bodyHash), body}
+		}
+		// The key must not stay reserved if the handler panics.
+		completed := false
+		defer func() {
+			if !completed {
+				database.ReleaseIdempotencyKey(context.WithoutCancel(c.Request.Context()), userID, key)
+			}
+		}()
+
+		c.Next()
+
+		ctx := context.WithoutCancel(c.Request.Context())
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+		if streamed && c.Request.Context().Err() != nil {
+			return
+		}
+
+		response := database.StoredResponse{StatusCode: status, Headers: map[string]string{}, Body: recorder.body.Bytes()}
+		if streamed {
+			// Hash whatever the handler left unread, such as the rest of
+			// an import stopped by an overlong line.
+			if _, err := io.Copy(io.Discard, c.Request.Body); err != nil {
+				return
+			}
+			response.BodyHash = hex.EncodeToString(bodyHash.Sum(nil))
+		}
+		for _, name := range replayedHeaders {
+			if value := recorder.Header().Get(name); value != "" {
+				response.Headers[name] = value
+			}
+		}
+		if err := database.SaveIdempotentResponse(ctx, userID, key, response); err != nil {
+			fmt.Printf("failed to store response for idempotency key: %v\n", err)
+			return
+		}
+		completed = true
+	}
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -15,6 +16,11 @@ func Logger() gin.HandlerFunc {
 
 		c.Next()
 
+		// Feed URLs carry a secret token.
+		if token := c.Param("token"); token != "" {
+			path = strings.Replace(path, token, "[redacted]", 1)
+		}
+
 		latency := time.Since(start)
 		statusCode := c.Writer.Status()
 
@@ -17,27 +17,75 @@ type User struct {
 }
 
 type Bookmark struct {
-	ID                uuid.UUID  `json:"id"`
-	UserID            uuid.UUID  `json:"user_id"`
-	TweetID           string     `json:"tweet_id"`
-	TweetText         string     `json:"tweet_text"`
-	AuthorUsername    string     `json:"author_username"`
-	AuthorDisplayName string     `json:"author_display_name"`
-	TweetURL          string     `json:"tweet_url"`
-	MediaURLs         []string   `json:"media_urls"`
-	BookmarkedAt      time.Time  `json:"bookmarked_at"`
-	CreatedAt         time.Time  `json:"created_at"`
-	Categories        []Category `json:"categories,omitempty"`
+	ID                uuid.UUID              `json:"id"`
+	UserID            uuid.UUID              `json:"user_id"`
+	TweetID           string                 `json:"tweet_id"`
+	TweetText         string                 `json:"tweet_text"`
+	AuthorUsername    string                 `json:"author_username"`
+	AuthorDisplayName string                 `json:"author_display_name"`
+	TweetURL          string                 `json:"tweet_url"`
+	MediaURLs         []string               `json:"media_urls"`
+	Title             string                 `json:"title,omitempty"`
+	Notes             string                 `json:"notes,omitempty"`
+	Metadata          map[string]interface{} `json:"metadata,omitempty"`
+	BookmarkedAt      time.Time              `json:"bookmarked_at"`
+	CreatedAt         time.Time              `json:"created_at"`
+	UpdatedAt         time.Time              `json:"updated_at"`
+	Lang              string                 `json:"lang"`
+	Status            string                 `json:"status"`
+	StatusCheckedAt   *time.Time             `json:"status_checked_at,omitempty"`
+	ArchivedAt        *time.Time             `json:"archived_at,omitempty"`
+	ReadAt            *time.Time             `json:"read_at,omitempty"`
+	DeletedAt         *time.Time             `json:"deleted_at,omitempty"`
+	Categories        []Category             `json:"categories,omitempty"`
+}
+
+// Bookmark status values recorded by the status checker. A bookmark starts
+// out unchecked until the checker has visited it at least once.
+const (
+	BookmarkStatusUnchecked  = "unchecked"
+	BookmarkStatusOK         = "ok"
+	BookmarkStatusDeleted    = "deleted"
+	BookmarkStatusProtected  = "protected"
+	BookmarkStatusNotFound   = "404"
+	BookmarkStatusRedirected = "redirected"
+)
+
+// IsValidBookmarkStatus reports whether status is one of the known bookmark statuses.
+func IsValidBookmarkStatus(status string) bool {
+	switch status {
+	case BookmarkStatusUnchecked, BookmarkStatusOK, BookmarkStatusDeleted,
+		BookmarkStatusProtected, BookmarkStatusNotFound, BookmarkStatusRedirected:
+		return true
+	}
+	return false
+}
+
+// BookmarkLink is a link found in a bookmark's tweet text together with the
+// result of the last time it was resolved.
+type BookmarkLink struct {
+	URL         string    `json:"url"`
+	ExpandedURL string    `json:"expanded_url"`
+	Status      string    `json:"status"`
+	CheckedAt   time.Time `json:"checked_at"`
+}
+
+// DeadBookmark is a bookmark that stopped resolving on its most recent check.
+type DeadBookmark struct {
+	Bookmark
+	PreviousStatus string         `json:"previous_status"`
+	Links          []BookmarkLink `json:"links,omitempty"`
 }
 
 type Category struct {
-	ID        uuid.UUID `json:"id"`
-	UserID    uuid.UUID `json:"user_id"`
-	Name      string    `json:"name"`
-	Color     string    `json:"color"`
-	Icon      string    `json:"icon"`
-	CreatedAt time.Time `json:"created_at"`
-	Count     int       `json:"count,omitempty"`
+	ID        uuid.UUID  `json:"id"`
+	UserID    uuid.UUID  `json:"user_id"`
+	Name      string     `json:"name"`
+	Color     string     `json:"color"`
+	Icon      string     `json:"icon"`
+	CreatedAt time.Time  `json:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	Count     int        `json:"count,omitempty"`
 }
 
 type BookmarkImportItem struct {
@@ -48,12 +96,177 @@ type BookmarkImportItem struct {
 	TweetURL          string   `json:"tweet_url"`
 	MediaURLs         []string `json:"media_urls"`
 	BookmarkedAt      string   `json:"bookmarked_at"`
+	// Categories names categories to assign the bookmark to. Names match
+	// existing categories case-insensitively; missing ones are created.
+	Categories []string `json:"categories,omitempty"`
+}
+
+// Outcomes of importing a single bookmark.
+const (
+	ImportOutcomeInserted  = "inserted"
+	ImportOutcomeUpdated   = "updated"
+	ImportOutcomeUnchanged = "unchanged"
+	ImportOutcomeFailed    = "failed"
+)
+
+// Import modes decide what happens to bookmarks the user already has.
+const (
+	ImportModeSkip    = "skip"
+	ImportModeUpdate  = "update"
+	ImportModeReplace = "replace"
+)
+
+func IsValidImportMode(mode string) bool {
+	switch mode {
+	case ImportModeSkip, ImportModeUpdate, ImportModeReplace:
+		return true
+	}
+	return false
+}
+
+// ImportItemResult reports what happened to one item of an import, in the
+// order the items were sent.
+type ImportItemResult struct {
+	Index      int        `json:"index"`
+	TweetID    string     `json:"tweet_id"`
+	Outcome    string     `json:"outcome"`
+	BookmarkID *uuid.UUID `json:"bookmark_id,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	// Fields holds per-field problems for items that failed validation.
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// Sources of an import, recorded with its sync session. The browser
+// extension marks its imports with ?source=extension.
+const (
+	ImportSourceAPI       = "api"
+	ImportSourceStream    = "stream"
+	ImportSourceArchive   = "archive"
+	ImportSourceService   = "service"
+	ImportSourceXAPI      = "x_api"
+	ImportSourceExtension = "extension"
+)
+
+// SyncSession records one import: where it came from and what it did.
+// HeadTweetIDs are the first tweets it received, in order; a complete
+// session is one whose batches were all saved.
+type SyncSession struct {
+	ID             uuid.UUID  `json:"id"`
+	Source         string     `json:"source"`
+	ItemCount      int        `json:"item_count"`
+	ImportedCount  int        `json:"imported_count"`
+	UpdatedCount   int        `json:"updated_count"`
+	DuplicateCount int        `json:"duplicate_count"`
+	FailedCount    int        `json:"failed_count"`
+	Complete       bool       `json:"complete"`
+	HeadTweetIDs   []string   `json:"-"`
+	OperationID    *uuid.UUID `json:"operation_id,omitempty"`
+	StartedAt      time.Time  `json:"started_at"`
+	FinishedAt     time.Time  `json:"finished_at"`
+}
+
+// SyncState tells the extension where its last sync started, so that it can
+// stop scrolling once it reaches tweets that were already synced.
+type SyncState struct {
+	// NewestTweetIDs are the first tweets of the last complete extension
+	// sync, in the order of the bookmarks page.
+	NewestTweetIDs []string      `json:"newest_tweet_ids"`
+	LastSyncedAt   *time.Time    `json:"last_synced_at,omitempty"`
+	Sessions       []SyncSession `json:"sessions"`
+}
+
+// Classes of an item in a dry-run import.
+const (
+	ImportPreviewNew         = "new"
+	ImportPreviewDuplicate   = "duplicate"
+	ImportPreviewWouldUpdate = "would_update"
+	ImportPreviewInvalid     = "invalid"
+)
+
+// ImportPreviewItem is what importing one item would do.
+type ImportPreviewItem struct {
+	Index      int        `json:"index"`
+	TweetID    string     `json:"tweet_id"`
+	Class      string     `json:"class"`
+	BookmarkID *uuid.UUID `json:"bookmark_id,omitempty"`
+	// Changes lists the fields an update would change.
+	Changes []FieldChange     `json:"changes,omitempty"`
+	Fields  map[string]string `json:"fields,omitempty"`
+	// AICategories are the categories auto-categorization would assign to a
+	// new bookmark.
+	AICategories []string `json:"ai_categories,omitempty"`
+}
+
+// ImportPreview is the result of a dry-run import, which writes nothing.
+type ImportPreview struct {
+	DryRun           bool   `json:"dry_run"`
+	Mode             string `json:"mode"`
+	NewCount         int    `json:"new_count"`
+	DuplicateCount   int    `json:"duplicate_count"`
+	WouldUpdateCount int    `json:"would_update_count"`
+	InvalidCount     int    `json:"invalid_count"`
+	AutoCategorize   bool   `json:"auto_categorize"`
+	// NewCategories are the categories the import would create, for item
+	// categories and AI suggestions.
+	NewCategories []string `json:"new_categories"`
+	// AICategoriesLimited is set when only some new bookmarks got an AI
+	// preview.
+	AICategoriesLimited bool                `json:"ai_categories_limited,omitempty"`
+	Items               []ImportPreviewItem `json:"items"`
+}
+
+// BookmarkRevision is an earlier version of a bookmark's imported content,
+// superseded at RevisedAt by a re-import with different content.
+type BookmarkRevision struct {
+	ID                uuid.UUID     `json:"id"`
+	BookmarkID        uuid.UUID     `json:"bookmark_id"`
+	TweetText         string        `json:"tweet_text"`
+	AuthorDisplayName string        `json:"author_display_name"`
+	MediaURLs         []string      `json:"media_urls"`
+	RevisedAt         time.Time     `json:"revised_at"`
+	Changes           []FieldChange `json:"changes"`
+}
+
+// FieldChange is a field-level diff between a revision and the version that replaced it.
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// Reasons for grouping bookmarks into a duplicate cluster.
+const (
+	DuplicateReasonSimilarText = "similar_text"
+	DuplicateReasonSharedLink  = "shared_link"
+)
+
+// DuplicateCluster is a group of bookmarks that look like the same content,
+// oldest first.
+type DuplicateCluster struct {
+	Bookmarks   []Bookmark `json:"bookmarks"`
+	Reasons     []string   `json:"reasons"`
+	SharedLinks []string   `json:"shared_links,omitempty"`
+}
+
+// MergeDuplicatesRequest keeps one bookmark and moves the categories and
+// notes of the others onto it before trashing them.
+type MergeDuplicatesRequest struct {
+	KeepID   string   `json:"keep_id" binding:"required"`
+	MergeIDs []string `json:"merge_ids" binding:"required"`
 }
 
 type BookmarkImport struct {
 	Bookmarks []BookmarkImportItem `json:"bookmarks"`
 }
 
+// TrashResponse lists soft-deleted items. Items are purged permanently once
+// they have been in the trash for RetentionDays.
+type TrashResponse struct {
+	Bookmarks     []Bookmark `json:"bookmarks"`
+	Categories    []Category `json:"categories"`
+	RetentionDays int        `json:"retention_days"`
+}
+
 type LoginResponse struct {
 	Token string `json:"token"`
 	User  User   `json:"user"`
@@ -63,16 +276,132 @@ type ErrorResponse struct {
 	Error string `json:"error"`
 }
 
+// ValidationErrorResponse reports invalid request fields, keyed by JSON field name.
+type ValidationErrorResponse struct {
+	Error  string            `json:"error"`
+	Fields map[string]string `json:"fields"`
+}
+
 type SuccessResponse struct {
 	Message string      `json:"message"`
 	Data    interface{} `json:"data,omitempty"`
 }
 
+// ImportResponse summarizes an import. DuplicateCount counts items whose
+// tweet the user already had and that were left unchanged.
 type ImportResponse struct {
 	Message         string `json:"message"`
 	ImportedCount   int    `json:"imported_count"`
+	UpdatedCount    int    `json:"updated_count"`
 	DuplicateCount  int    `json:"duplicate_count"`
+	FailedCount     int    `json:"failed_count"`
 	AutoCategorized int    `json:"auto_categorized,omitempty"`
+	// CategoriesAssigned counts assignments made from categories that came
+	// with the imported items, such as tags from another service.
+	CategoriesAssigned int `json:"categories_assigned,omitempty"`
+	// OperationID is sent in the X-Operation-ID header.
+	OperationID *uuid.UUID         `json:"-"`
+	Mode        string             `json:"mode"`
+	Results     []ImportItemResult `json:"results"`
+}
+
+// ImportProgress is one line of a streamed import response. A line is sent
+// after every batch with the counts so far and the batch's failed items;
+// the last line has Done set.
+type ImportProgress struct {
+	Done            bool               `json:"done"`
+	Batch           int                `json:"batch"`
+	Processed       int                `json:"processed"`
+	ImportedCount   int                `json:"imported_count"`
+	UpdatedCount    int                `json:"updated_count"`
+	DuplicateCount  int                `json:"duplicate_count"`
+	FailedCount     int                `json:"failed_count"`
+	AutoCategorized int                `json:"auto_categorized"`
+	Errors          []ImportItemResult `json:"errors,omitempty"`
+	OperationID     *uuid.UUID         `json:"operation_id,omitempty"`
+	Error           string             `json:"error,omitempty"`
+}
+
+// Import job statuses.
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+	JobStatusFailed    = "failed"
+	JobStatusCancelled = "cancelled"
+)
+
+// ImportJob is an import running in the background. Processed counts the
+// items handled so far out of Total; Errors lists the failed items.
+type ImportJob struct {
+	ID              uuid.UUID          `json:"id"`
+	UserID          uuid.UUID          `json:"user_id"`
+	Status          string             `json:"status"`
+	Mode            string             `json:"mode"`
+	Source          string             `json:"source"`
+	Total           int                `json:"total"`
+	Processed       int                `json:"processed"`
+	ImportedCount   int                `json:"imported_count"`
+	UpdatedCount    int                `json:"updated_count"`
+	DuplicateCount  int                `json:"duplicate_count"`
+	FailedCount     int                `json:"failed_count"`
+	AutoCategorized int                `json:"auto_categorized"`
+	Errors          []ImportItemResult `json:"errors"`
+	OperationID     *uuid.UUID         `json:"operation_id,omitempty"`
+	Error           string             `json:"error,omitempty"`
+	CancelRequested bool               `json:"cancel_requested"`
+	CreatedAt       time.Time          `json:"created_at"`
+	StartedAt       *time.Time         `json:"started_at,omitempty"`
+	FinishedAt      *time.Time         `json:"finished_at,omitempty"`
+}
+
+// IsFinished reports whether the job has stopped for good.
+func (j *ImportJob) IsFinished() bool {
+	return j.Status == JobStatusCompleted || j.Status == JobStatusFailed || j.Status == JobStatusCancelled
+}
+
+// XToken is the OAuth token a user granted when signing in with X, kept so
+// their bookmarks can be synced from the X API.
+type XToken struct {
+	AccessToken  string
+	RefreshToken string
+	TokenType    string
+	Expiry       time.Time
+}
+
+// X sync status values. A sync is idle between runs, and rate_limited when
+// it stopped at the API's rate limit and resumes once the limit resets.
+const (
+	XSyncStatusIdle        = "idle"
+	XSyncStatusRunning     = "running"
+	XSyncStatusRateLimited = "rate_limited"
+	XSyncStatusFailed      = "failed"
+)
+
+// XSync is the state of a user's bookmark sync from the X API. Cursor is the
+// pagination token of the next page when the last run stopped part way.
+type XSync struct {
+	UserID        uuid.UUID  `json:"-"`
+	Status        string     `json:"status"`
+	Cursor        string     `json:"-"`
+	Resuming      bool       `json:"resuming"`
+	NextSyncAt    time.Time  `json:"next_sync_at"`
+	LastSyncedAt  *time.Time `json:"last_synced_at,omitempty"`
+	LastRunAt     *time.Time `json:"last_run_at,omitempty"`
+	ImportedCount int        `json:"imported_count"`
+	UpdatedCount  int        `json:"updated_count"`
+	FailedCount   int        `json:"failed_count"`
+	OperationID   *uuid.UUID `json:"operation_id,omitempty"`
+	Error         string     `json:"error,omitempty"`
+}
+
+// BookmarkFilter narrows bookmark listings and searches. Zero values mean no filtering.
+type BookmarkFilter struct {
+	CategoryID *uuid.UUID
+	Status     string
+	Lang       string
+	Archived   *bool
+	Read       *bool
 }
 
 type PaginationParams struct {
@@ -82,11 +411,23 @@ type PaginationParams struct {
 }
 
 type BookmarksResponse struct {
-	Bookmarks  []Bookmark `json:"bookmarks"`
-	Total      int        `json:"total"`
-	Page       int        `json:"page"`
-	PageSize   int        `json:"page_size"`
-	TotalPages int        `json:"total_pages"`
+	Bookmarks  []Bookmark      `json:"bookmarks"`
+	Total      int             `json:"total"`
+	Page       int             `json:"page"`
+	PageSize   int             `json:"page_size"`
+	TotalPages int             `json:"total_pages"`
+	Facets     *BookmarkFacets `json:"facets,omitempty"`
+}
+
+// BookmarkFacets summarizes how the bookmarks matching a listing or search
+// are distributed, ignoring the facet's own filter.
+type BookmarkFacets struct {
+	Languages []FacetCount `json:"languages"`
+}
+
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
 }
 
 type CreateCategoryRequest struct {
@@ -105,6 +446,159 @@ type AssignCategoryRequest struct {
 	CategoryID string `json:"category_id" binding:"required"`
 }
 
+// UpdateBookmarkRequest is a partial update; omitted fields are left unchanged.
+// Metadata keys are merged into the existing metadata and a null value removes a key.
+type UpdateBookmarkRequest struct {
+	Title        *string                `json:"title"`
+	TweetText    *string                `json:"tweet_text"`
+	BookmarkedAt *string                `json:"bookmarked_at"`
+	Notes        *string                `json:"notes"`
+	Metadata     map[string]interface{} `json:"metadata"`
+}
+
+// BookmarkUpdate is a validated UpdateBookmarkRequest. Nil fields are left unchanged.
+type BookmarkUpdate struct {
+	Title        *string
+	TweetText    *string
+	Lang         string
+	BookmarkedAt *time.Time
+	Notes        *string
+	Metadata     map[string]interface{}
+}
+
+// Bulk actions accepted by POST /api/bookmarks/bulk.
+const (
+	BulkActionDelete         = "delete"
+	BulkActionAssignCategory = "assign_category"
+	BulkActionRemoveCategory = "remove_category"
+	BulkActionArchive        = "archive"
+	BulkActionMarkRead       = "mark_read"
+)
+
+// BulkActionRequest selects bookmarks either by explicit IDs or by Filter
+// (the same filters and search syntax as the list and search endpoints) and
+// applies Action to all of them.
+type BulkActionRequest struct {
+	IDs        []string    `json:"ids"`
+	Filter     *BulkFilter `json:"filter"`
+	Action     string      `json:"action" binding:"required"`
+	CategoryID string      `json:"category_id"`
+}
+
+type BulkFilter struct {
+	Query      string `json:"q"`
+	CategoryID string `json:"category_id"`
+	Status     string `json:"status"`
+	Lang       string `json:"lang"`
+	Archived   *bool  `json:"archived"`
+	Read       *bool  `json:"read"`
+}
+
+// BulkAction is a validated BulkActionRequest. When Filter is nil the
+// bookmarks in IDs are used.
+type BulkAction struct {
+	Action     string
+	CategoryID *uuid.UUID
+	IDs        []uuid.UUID
+	Query      string
+	Filter     *BookmarkFilter
+}
+
+// BulkActionResult reports how many bookmarks were selected and how many of
+// them the action actually changed.
+type BulkActionResult struct {
+	Action   string `json:"action"`
+	Matched  int    `json:"matched"`
+	Affected int    `json:"affected"`
+	// OperationID is sent in the X-Operation-ID header.
+	OperationID *uuid.UUID `json:"-"`
+}
+
+// Operation kinds recorded in the operation log.
+const (
+	OperationImport          = "import"
+	OperationUpdateBookmark  = "update_bookmark"
+	OperationDeleteBookmark  = "delete_bookmark"
+	OperationAssignCategory  = "assign_category"
+	OperationRemoveCategory  = "remove_category"
+	OperationCreateCategory  = "create_category"
+	OperationUpdateCategory  = "update_category"
+	OperationDeleteCategory  = "delete_category"
+	OperationRestore         = "restore"
+	OperationAICategorize    = "ai_categorize"
+	OperationMergeDuplicates = "merge_duplicates"
+	// Bulk operations are recorded as "bulk_" followed by the bulk action.
+	OperationBulkPrefix = "bulk_"
+)
+
+// Operation is an entry in the operation log. Inverse holds the changes that
+// revert the operation; they are applied by POST /api/operations/:id/undo.
+type Operation struct {
+	ID        uuid.UUID        `json:"id"`
+	UserID    uuid.UUID        `json:"user_id"`
+	Kind      string           `json:"kind"`
+	Inverse   OperationInverse `json:"-"`
+	CreatedAt time.Time        `json:"created_at"`
+	UndoneAt  *time.Time       `json:"undone_at,omitempty"`
+}
+
+// OperationInverse lists the changes that undo an operation. Every list is
+// applied, so a single operation can combine several kinds of changes (an
+// AI run both creates categories and assigns bookmarks to them).
+type OperationInverse struct {
+	TrashBookmarks     []uuid.UUID        `json:"trash_bookmarks,omitempty"`
+	TrashCategories    []uuid.UUID        `json:"trash_categories,omitempty"`
+	RestoreBookmarks   []uuid.UUID        `json:"restore_bookmarks,omitempty"`
+	RestoreCategories  []uuid.UUID        `json:"restore_categories,omitempty"`
+	AddAssignments     []BookmarkCategory `json:"add_assignments,omitempty"`
+	RemoveAssignments  []BookmarkCategory `json:"remove_assignments,omitempty"`
+	UnarchiveBookmarks []uuid.UUID        `json:"unarchive_bookmarks,omitempty"`
+	MarkUnread         []uuid.UUID        `json:"mark_unread,omitempty"`
+	BookmarkEdits      []BookmarkSnapshot `json:"bookmark_edits,omitempty"`
+	CategoryEdits      []Category         `json:"category_edits,omitempty"`
+}
+
+// BookmarkCategory is a single bookmark-to-category assignment.
+type BookmarkCategory struct {
+	BookmarkID uuid.UUID `json:"bookmark_id"`
+	CategoryID uuid.UUID `json:"category_id"`
+}
+
+// BookmarkSnapshot holds the editable fields of a bookmark as they were
+// before an update. Imports also change the tweet's author, link and media;
+// snapshots taken by an import set those too, and fields left nil are not
+// restored.
+type BookmarkSnapshot struct {
+	ID                uuid.UUID              `json:"id"`
+	Title             string                 `json:"title"`
+	TweetText         string                 `json:"tweet_text"`
+	Lang              string                 `json:"lang"`
+	BookmarkedAt      time.Time              `json:"bookmarked_at"`
+	Notes             string                 `json:"notes"`
+	Metadata          map[string]interface{} `json:"metadata"`
+	AuthorUsername    *string                `json:"author_username,omitempty"`
+	AuthorDisplayName *string                `json:"author_display_name,omitempty"`
+	TweetURL          *string                `json:"tweet_url,omitempty"`
+	MediaURLs         *[]string              `json:"media_urls,omitempty"`
+}
+
 type UpdatePreferencesRequest struct {
 	AutoCategorize *bool `json:"auto_categorize"`
 }
+
+// Feed is a feed of a user's bookmarks, or of one category, that feed
+// readers fetch with the secret token in its URLs. The token and URLs are
+// only known when the feed is created.
+type Feed struct {
+	ID         uuid.UUID         `json:"id"`
+	UserID     uuid.UUID         `json:"-"`
+	CategoryID *uuid.UUID        `json:"category_id,omitempty"`
+	Token      string            `json:"token,omitempty"`
+	URLs       map[string]string `json:"urls,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	LastUsedAt *time.Time        `json:"last_used_at,omitempty"`
+}
+
+type CreateFeedRequest struct {
+	CategoryID *uuid.UUID `json:"category_id"`
+}
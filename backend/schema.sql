@@ -51,3 +51,202 @@ CREATE INDEX IF NOT EXISTS idx_bookmarks_tweet_id ON bookmarks(tweet_id);
 CREATE INDEX IF NOT EXISTS idx_categories_user_id ON categories(user_id);
 CREATE INDEX IF NOT EXISTS idx_bookmark_categories_bookmark_id ON bookmark_categories(bookmark_id);
 CREATE INDEX IF NOT EXISTS idx_bookmark_categories_category_id ON bookmark_categories(category_id);
+
+-- Bookmark status tracking (dead tweet and link-rot detection)
+ALTER TABLE bookmarks ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'unchecked';
+ALTER TABLE bookmarks ADD COLUMN IF NOT EXISTS previous_status TEXT;
+ALTER TABLE bookmarks ADD COLUMN IF NOT EXISTS status_checked_at TIMESTAMP;
+
+-- Links found in tweet text and the result of their last check
+CREATE TABLE IF NOT EXISTS bookmark_links (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    bookmark_id UUID REFERENCES bookmarks(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    expanded_url TEXT,
+    status TEXT NOT NULL,
+    checked_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE(bookmark_id, url)
+);
+
+CREATE INDEX IF NOT EXISTS idx_bookmarks_user_status ON bookmarks(user_id, status);
+CREATE INDEX IF NOT EXISTS idx_bookmarks_status_checked_at ON bookmarks(status_checked_at);
+CREATE INDEX IF NOT EXISTS idx_bookmark_links_bookmark_id ON bookmark_links(bookmark_id);
+
+-- Language detection and per-language full-text search
+ALTER TABLE bookmarks ADD COLUMN IF NOT EXISTS lang TEXT NOT NULL DEFAULT 'und';
+ALTER TABLE bookmarks ADD COLUMN IF NOT EXISTS search_config REGCONFIG NOT NULL DEFAULT 'simple';
+ALTER TABLE bookmarks ADD COLUMN IF NOT EXISTS search_vector TSVECTOR
+    GENERATED ALWAYS AS (to_tsvector(search_config, COALESCE(tweet_text, ''))) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_bookmarks_user_lang ON bookmarks(user_id, lang);
+CREATE INDEX IF NOT EXISTS idx_bookmarks_search_vector ON bookmarks USING GIN(search_vector);
+
+-- Editable bookmark metadata
+ALTER TABLE bookmarks ADD COLUMN IF NOT EXISTS title TEXT NOT NULL DEFAULT '';
+ALTER TABLE bookmarks ADD COLUMN IF NOT EXISTS notes TEXT NOT NULL DEFAULT '';
+ALTER TABLE bookmarks ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE bookmarks ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+-- Soft deletion (trash)
+ALTER TABLE bookmarks ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_bookmarks_deleted_at ON bookmarks(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_categories_deleted_at ON categories(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Archive and read state
+ALTER TABLE bookmarks ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP;
+ALTER TABLE bookmarks ADD COLUMN IF NOT EXISTS read_at TIMESTAMP;
+
+-- Operation log for undo
+CREATE TABLE IF NOT EXISTS operations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    inverse JSONB NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    undone_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_operations_user_created ON operations(user_id, created_at DESC);
+
+-- Earlier versions of bookmarks whose content changed on re-import
+CREATE TABLE IF NOT EXISTS bookmark_revisions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    bookmark_id UUID REFERENCES bookmarks(id) ON DELETE CASCADE,
+    tweet_text TEXT,
+    author_display_name TEXT,
+    media_urls TEXT[],
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_bookmark_revisions_bookmark_id ON bookmark_revisions(bookmark_id, created_at DESC);
+
+-- Imports processed in the background. items holds the request payload
+-- until the job finishes.
+CREATE TABLE IF NOT EXISTS import_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'queued',
+    mode TEXT NOT NULL,
+    items JSONB,
+    total INTEGER NOT NULL DEFAULT 0,
+    processed INTEGER NOT NULL DEFAULT 0,
+    imported_count INTEGER NOT NULL DEFAULT 0,
+    updated_count INTEGER NOT NULL DEFAULT 0,
+    duplicate_count INTEGER NOT NULL DEFAULT 0,
+    failed_count INTEGER NOT NULL DEFAULT 0,
+    auto_categorized INTEGER NOT NULL DEFAULT 0,
+    errors JSONB NOT NULL DEFAULT '[]',
+    operation_id UUID,
+    error TEXT,
+    cancel_requested BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT NOW(),
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_import_jobs_user_id ON import_jobs(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_import_jobs_queued ON import_jobs(created_at) WHERE status = 'queued';
+
+-- OAuth tokens from signing in with X, used to sync bookmarks from the X API
+CREATE TABLE IF NOT EXISTS x_tokens (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    access_token TEXT NOT NULL,
+    refresh_token TEXT,
+    token_type TEXT,
+    expires_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Bookmark sync from the X API. cursor is the pagination token to resume
+-- from when a run stopped part way, such as at a rate limit.
+CREATE TABLE IF NOT EXISTS x_syncs (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'idle',
+    cursor TEXT,
+    next_sync_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_synced_at TIMESTAMP,
+    last_run_at TIMESTAMP,
+    imported_count INTEGER NOT NULL DEFAULT 0,
+    updated_count INTEGER NOT NULL DEFAULT 0,
+    failed_count INTEGER NOT NULL DEFAULT 0,
+    operation_id UUID,
+    error TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_x_syncs_next_sync_at ON x_syncs(next_sync_at);
+
+-- Responses to requests sent with an Idempotency-Key header, replayed when
+-- the request is retried. status_code is NULL while the request runs.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    key TEXT NOT NULL,
+    request_hash TEXT NOT NULL,
+    status_code INTEGER,
+    headers JSONB,
+    response BYTEA,
+    created_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (user_id, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created_at ON idempotency_keys(user_id, created_at);
+
+-- Import history. head_tweet_ids are the first tweets an import received,
+-- which the extension uses to stop scrolling at already-synced bookmarks.
+ALTER TABLE import_jobs ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT 'api';
+
+CREATE TABLE IF NOT EXISTS sync_sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    source TEXT NOT NULL,
+    item_count INTEGER NOT NULL DEFAULT 0,
+    imported_count INTEGER NOT NULL DEFAULT 0,
+    updated_count INTEGER NOT NULL DEFAULT 0,
+    duplicate_count INTEGER NOT NULL DEFAULT 0,
+    failed_count INTEGER NOT NULL DEFAULT 0,
+    complete BOOLEAN NOT NULL DEFAULT TRUE,
+    head_tweet_ids TEXT[] NOT NULL DEFAULT '{}',
+    operation_id UUID,
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_sessions_user_finished ON sync_sessions(user_id, finished_at DESC);
+
+-- Feeds for feed readers, which cannot send an Authorization header. Each
+-- feed is fetched with a secret token in its URL; only the token's SHA-256
+-- hash is stored. A feed without a category covers all bookmarks.
+CREATE TABLE IF NOT EXISTS feeds (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    category_id UUID REFERENCES categories(id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP DEFAULT NOW(),
+    last_used_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_feeds_user_id ON feeds(user_id);
+
+-- Running import jobs refresh heartbeat_at while their worker is alive. A
+-- job whose heartbeat goes stale was abandoned by a worker that stopped, and
+-- is failed by whichever worker notices first.
+ALTER TABLE import_jobs ADD COLUMN IF NOT EXISTS heartbeat_at TIMESTAMP;
+
+-- Running X syncs refresh heartbeat_at the same way; an abandoned sync is
+-- made due again and resumes from its cursor. x_tokens.access_token and
+-- refresh_token hold the tokens encrypted with X_TOKEN_ENCRYPTION_KEY.
+ALTER TABLE x_syncs ADD COLUMN IF NOT EXISTS heartbeat_at TIMESTAMP;
+
+-- Streamed requests are not buffered, so the SHA-256 hash of their body is
+-- computed while the handler reads it and stored with the response; a retry
+-- with the same key must send the same body.
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS body_hash TEXT;
+
+-- When the status checker last tried a bookmark, whether or not the check
+-- succeeded. Bookmarks are picked by it, so one whose check keeps failing
+-- waits STATUS_CHECK_MAX_AGE like the others instead of blocking the queue.
+ALTER TABLE bookmarks ADD COLUMN IF NOT EXISTS status_attempted_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_bookmarks_status_attempted_at
+    ON bookmarks((COALESCE(status_attempted_at, status_checked_at))) WHERE deleted_at IS NULL;
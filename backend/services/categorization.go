@@ -10,16 +10,35 @@ import (
 	"github.com/google/uuid"
 )
 
+// CategorizationResult describes what an AI categorization run changed.
+type CategorizationResult struct {
+	// Categorized is the number of bookmark-category assignments made.
+	Categorized int
+	// NewCategories is the number of categories created for suggestions
+	// that did not match an existing category.
+	NewCategories int
+	Assignments   []models.BookmarkCategory
+	CreatedIDs    []uuid.UUID
+}
+
+// Inverse returns the changes that undo the run.
+func (r *CategorizationResult) Inverse() models.OperationInverse {
+	return models.OperationInverse{
+		RemoveAssignments: r.Assignments,
+		TrashCategories:   r.CreatedIDs,
+	}
+}
+
 // CategorizeBookmarksForUser applies AI categorization to the provided bookmarks.
-// Returns the number of bookmark-category assignments made and new categories created.
-func CategorizeBookmarksForUser(ctx context.Context, userID uuid.UUID, bookmarks []models.Bookmark) (int, int, error) {
+func CategorizeBookmarksForUser(ctx context.Context, userID uuid.UUID, bookmarks []models.Bookmark) (*CategorizationResult, error) {
+	result := &CategorizationResult{}
 	if len(bookmarks) == 0 {
-		return 0, 0, nil
+		return result, nil
 	}
 
 	categories, err := database.GetCategoriesByUserID(ctx, userID)
 	if err != nil {
-		return 0, 0, err
+		return nil, err
 	}
 
 	categoryNames := make([]string, len(categories))
@@ -30,9 +49,6 @@ func CategorizeBookmarksForUser(ctx context.Context, userID uuid.UUID, bookmarks
 		categoryMap[strings.ToLower(cat.Name)] = cat.ID
 	}
 
-	categorizedCount := 0
-	newCategoriesCount := 0
-
 	for _, bookmark := range bookmarks {
 		if strings.TrimSpace(bookmark.TweetText) == "" {
 			continue
@@ -67,16 +83,19 @@ func CategorizeBookmarksForUser(ctx context.Context, userID uuid.UUID, bookmarks
 				catID = newCategory.ID
 				categoryMap[lookupKey] = catID
 				categoryNames = append(categoryNames, catName)
-				newCategoriesCount++
+				result.NewCategories++
+				result.CreatedIDs = append(result.CreatedIDs, catID)
 			}
 
-			if err := database.AssignBookmarkToCategory(ctx, bookmark.ID, catID, userID); err == nil {
-				categorizedCount++
+			assigned, err := database.AssignBookmarkToCategory(ctx, bookmark.ID, catID, userID)
+			if err == nil && assigned {
+				result.Categorized++
+				result.Assignments = append(result.Assignments, models.BookmarkCategory{BookmarkID: bookmark.ID, CategoryID: catID})
 			}
 		}
 	}
 
-	return categorizedCount, newCategoriesCount, nil
+	return result, nil
 }
 
 // Helper functions for default colors and icons based on category name
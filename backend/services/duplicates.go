@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+	"twitter-bookmarks-api/database"
+	"twitter-bookmarks-api/models"
+	"twitter-bookmarks-api/simhash"
+
+	"github.com/google/uuid"
+)
+
+// maxSimHashDistance is how many bits two fingerprints may differ in for
+// their texts to count as near-duplicates.
+const maxSimHashDistance = 3
+
+// simHashBands splits fingerprints into bands for candidate lookup. Two
+// fingerprints within maxSimHashDistance bits share at least one band as long
+// as there are more bands than allowed differing bits.
+const simHashBands = maxSimHashDistance + 1
+
+// trackingParams are query parameters that do not change which page a link
+// points to.
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"ref":     true,
+	"ref_src": true,
+	"ref_url": true,
+	"s":       true,
+	"t":       true,
+}
+
+// FindDuplicateClusters groups the user's bookmarks that have near-identical
+// text (by SimHash) or link to the same page. Clusters are connected
+// components, so A and C end up together when both resemble B.
+func FindDuplicateClusters(ctx context.Context, userID uuid.UUID) ([]models.DuplicateCluster, error) {
+	bookmarks, err := database.GetAllBookmarksByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	expanded, err := database.GetExpandedLinksByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sets := newDisjointSet(len(bookmarks))
+	reasons := make(map[int]map[string]bool)
+	link := func(a, b int, reason string) {
+		sets.union(a, b)
+		if reasons[a] == nil {
+			reasons[a] = make(map[string]bool)
+		}
+		reasons[a][reason] = true
+	}
+
+	// Similar text: only fingerprints that share a band are compared.
+	fingerprints := make([]uint64, len(bookmarks))
+	buckets := make(map[[2]uint64][]int)
+	for i, b := range bookmarks {
+		fp, ok := simhash.Fingerprint(b.TweetText)
+		if !ok {
+			continue
+		}
+		fingerprints[i] = fp
+		for band := 0; band < simHashBands; band++ {
+			key := [2]uint64{uint64(band), (fp >> (uint(band) * 16)) & 0xffff}
+			for _, j := range buckets[key] {
+				if simhash.Distance(fp, fingerprints[j]) <= maxSimHashDistance {
+					link(i, j, models.DuplicateReasonSimilarText)
+				}
+			}
+			buckets[key] = append(buckets[key], i)
+		}
+	}
+
+	// Shared links: expanded URLs from the status checker plus any
+	// non-shortened links in the text.
+	byLink := make(map[string][]int)
+	linksOf := make([][]string, len(bookmarks))
+	for i, b := range bookmarks {
+		seen := make(map[string]bool)
+		candidates := append([]string(nil), expanded[b.ID]...)
+		for _, raw := range extractLinks(b.TweetText) {
+			if u, err := url.Parse(raw); err == nil && !shortenerHosts[strings.ToLower(u.Hostname())] {
+				candidates = append(candidates, raw)
+			}
+		}
+		for _, raw := range candidates {
+			key := normalizeLink(raw)
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			linksOf[i] = append(linksOf[i], key)
+			for _, j := range byLink[key] {
+				link(i, j, models.DuplicateReasonSharedLink)
+			}
+			byLink[key] = append(byLink[key], i)
+		}
+	}
+
+	members := make(map[int][]int)
+	for i := range bookmarks {
+		root := sets.find(i)
+		members[root] = append(members[root], i)
+	}
+
+	var clusters []models.DuplicateCluster
+	for _, indexes := range members {
+		if len(indexes) < 2 {
+			continue
+		}
+
+		reasonSet := make(map[string]bool)
+		linkCount := make(map[string]int)
+		cluster := models.DuplicateCluster{}
+		for _, i := range indexes {
+			for reason := range reasons[i] {
+				reasonSet[reason] = true
+			}
+			for _, key := range linksOf[i] {
+				linkCount[key]++
+			}
+
+			cluster.Bookmarks = append(cluster.Bookmarks, bookmarks[i])
+		}
+		for reason := range reasonSet {
+			cluster.Reasons = append(cluster.Reasons, reason)
+		}
+		for key, count := range linkCount {
+			if count > 1 {
+				cluster.SharedLinks = append(cluster.SharedLinks, key)
+			}
+		}
+		sort.Strings(cluster.Reasons)
+		sort.Strings(cluster.SharedLinks)
+		sort.Slice(cluster.Bookmarks, func(a, b int) bool {
+			return cluster.Bookmarks[a].BookmarkedAt.Before(cluster.Bookmarks[b].BookmarkedAt)
+		})
+		clusters = append(clusters, cluster)
+	}
+
+	var memberIDs []uuid.UUID
+	for _, cluster := range clusters {
+		for _, b := range cluster.Bookmarks {
+			memberIDs = append(memberIDs, b.ID)
+		}
+	}
+	if len(memberIDs) > 0 {
+		categories, err := database.GetCategoriesByBookmarkIDs(ctx, memberIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, cluster := range clusters {
+			for i := range cluster.Bookmarks {
+				cluster.Bookmarks[i].Categories = categories[cluster.Bookmarks[i].ID]
+			}
+		}
+	}
+
+	sort.Slice(clusters, func(a, b int) bool {
+		if len(clusters[a].Bookmarks) != len(clusters[b].Bookmarks) {
+			return len(clusters[a].Bookmarks) > len(clusters[b].Bookmarks)
+		}
+		return clusters[a].Bookmarks[0].BookmarkedAt.Before(clusters[b].Bookmarks[0].BookmarkedAt)
+	})
+	return clusters, nil
+}
+
+// normalizeLink reduces a URL to the parts that identify the page: host
+// without "www.", path without trailing slash, and non-tracking query
+// parameters. Scheme and fragment are dropped.
+func normalizeLink(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return ""
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	query := u.Query()
+	for key := range query {
+		if trackingParams[strings.ToLower(key)] || strings.HasPrefix(strings.ToLower(key), "utm_") {
+			query.Del(key)
+		}
+	}
+
+	normalized := host + strings.TrimRight(u.EscapedPath(), "/")
+	if encoded := query.Encode(); encoded != "" {
+		normalized += "?" + encoded
+	}
+	return normalized
+}
+
+// disjointSet is a union-find structure over bookmark indexes.
+type disjointSet struct {
+	parent []int
+}
+
+func newDisjointSet(n int) *disjointSet {
+	parent := make([]int, n)
+	for i := range parent {
+		parent[i] = i
+	}
+	return &disjointSet{parent: parent}
+}
+
+func (s *disjointSet) find(i int) int {
+	for s.parent[i] != i {
+		s.parent[i] = s.parent[s.parent[i]]
+		i = s.parent[i]
+	}
+	return i
+}
+
+func (s *disjointSet) union(a, b int) {
+	rootA, rootB := s.find(a), s.find(b)
+	if rootA != rootB {
+		s.parent[rootA] = rootB
+	}
+}
@@ -0,0 +1,32 @@
+package services
+
+import "testing"
+
+func TestNormalizeLink(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"https://example.com/article", "example.com/article"},
+		{"http://www.Example.com/article/", "example.com/article"},
+		{"https://example.com/article#comments", "example.com/article"},
+		{"  https://example.com/a  ", "example.com/a"},
+		{"https://example.com/", "example.com"},
+		{"https://example.com:8080/a", "example.com/a"},
+		{"https://example.com/a?utm_source=x&UTM_Medium=y&fbclid=1&gclid=2", "example.com/a"},
+		{"https://example.com/a?ref=tw&ref_src=twsrc&ref_url=u&s=20&t=abc", "example.com/a"},
+		{"https://example.com/watch?v=abc&utm_campaign=z", "example.com/watch?v=abc"},
+		{"https://example.com/search?b=2&a=1", "example.com/search?a=1&b=2"},
+		{"https://example.com/caf%C3%A9", "example.com/caf%C3%A9"},
+		{"https://Example.com/Path", "example.com/Path"},
+		{"example.com/article", ""},
+		{"not a url", ""},
+		{"", ""},
+		{"http://[::1", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeLink(tt.raw); got != tt.want {
+			t.Errorf("normalizeLink(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"twitter-bookmarks-api/database"
+	"twitter-bookmarks-api/models"
+
+	"github.com/google/uuid"
+)
+
+// feedTokenBytes is the amount of randomness in a feed token.
+const feedTokenBytes = 32
+
+// CreateFeed creates a feed of the user's bookmarks, or of one category if
+// categoryID is set, and returns it with its token. Only the token's hash
+// is stored, so this is the one time it is known.
+func CreateFeed(ctx context.Context, userID uuid.UUID, categoryID *uuid.UUID) (*models.Feed, error) {
+	raw := make([]byte, feedTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	feed := &models.Feed{UserID: userID, CategoryID: categoryID, Token: token}
+	if err := database.CreateFeed(ctx, feed, hashFeedToken(token)); err != nil {
+		return nil, err
+	}
+	return feed, nil
+}
+
+// FeedForToken returns the feed a token belongs to, or
+// database.ErrFeedNotFound if it was revoked or never existed.
+func FeedForToken(ctx context.Context, token string) (*models.Feed, error) {
+	return database.UseFeed(ctx, hashFeedToken(token))
+}
+
+func hashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// Running import jobs and X syncs refresh their heartbeat every
+	// heartbeatInterval; one not refreshed for leaseTimeout is taken to have
+	// been abandoned by a worker that stopped.
+	heartbeatInterval = 30 * time.Second
+	leaseTimeout      = 2 * time.Minute
+)
+
+// startHeartbeat calls touch every heartbeatInterval until the returned
+// function is called, so that long work does not look abandoned. Failures
+// are logged with the given name.
+func startHeartbeat(ctx context.Context, name string, touch func(context.Context) error) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := touch(ctx); err != nil && ctx.Err() == nil {
+					fmt.Printf("failed to refresh heartbeat of %s: %v\n", name, err)
+				}
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"twitter-bookmarks-api/database"
+	"twitter-bookmarks-api/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultImportJobPollInterval = 5 * time.Second
+	importJobBatchSize           = 500
+	// maxImportJobErrors caps how many failed items a job keeps details for;
+	// FailedCount still counts all of them.
+	maxImportJobErrors = 1000
+)
+
+// importJobWake lets EnqueueImportJob start the worker without waiting for
+// the next poll.
+var importJobWake = make(chan struct{}, 1)
+
+// EnqueueImportJob queues items to be imported by the background worker.
+func EnqueueImportJob(ctx context.Context, userID uuid.UUID, mode, source string, items []models.BookmarkImportItem) (*models.ImportJob, error) {
+	job, err := database.CreateImportJob(ctx, userID, mode, source, items)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case importJobWake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// StartImportWorker processes queued import jobs one at a time until ctx is
+// cancelled, checking for new jobs every IMPORT_JOB_POLL_INTERVAL. Each poll
+// also fails jobs whose worker stopped heartbeating, whether it belonged to
+// a previous process or to another instance; jobs other instances are still
+// running are left alone.
+func StartImportWorker(ctx context.Context) {
+	interval := durationFromEnv("IMPORT_JOB_POLL_INTERVAL", defaultImportJobPollInterval)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			failAbandonedImportJobs(ctx)
+			for ctx.Err() == nil {
+				job, items, err := database.ClaimImportJob(ctx)
+				if err != nil {
+					fmt.Printf("import worker: failed to claim job: %v\n", err)
+					break
+				}
+				if job == nil {
+					break
+				}
+				runImportJob(ctx, job, items)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-importJobWake:
+			}
+		}
+	}()
+}
+
+func failAbandonedImportJobs(ctx context.Context) {
+	n, err := database.FailAbandonedImportJobs(ctx, leaseTimeout)
+	if err != nil {
+		fmt.Printf("import worker: failed to reset abandoned jobs: %v\n", err)
+	} else if n > 0 {
+		fmt.Printf("import worker: marked %d abandoned jobs as failed\n", n)
+	}
+}
+
+// runImportJob imports a job's items in batches, saving progress after each
+// batch and stopping early if the job was cancelled. Batches that finished
+// before a cancellation stay imported and can be undone through the job's
+// operation.
+func runImportJob(ctx context.Context, job *models.ImportJob, items []models.BookmarkImportItem) {
+	stopHeartbeat := startHeartbeat(ctx, "import job "+job.ID.String(), func(ctx context.Context) error {
+		return database.TouchImportJob(ctx, job.ID)
+	})
+	defer stopHeartbeat()
+
+	job.Errors = []models.ImportItemResult{}
+	session, err := NewImportSession(ctx, job.UserID, job.Mode, job.Source, true)
+	if err != nil {
+		fmt.Printf("import worker: failed to start job %s: %v\n", job.ID, err)
+		job.Status = models.JobStatusFailed
+		job.Error = "failed to start import"
+		if err := database.FinishImportJob(context.WithoutCancel(ctx), job); err != nil {
+			fmt.Printf("import worker: failed to finish job %s: %v\n", job.ID, err)
+		}
+		return
+	}
+
+	cancelled := false
+	for start := 0; start < len(items) && !cancelled; start += importJobBatchSize {
+		end := min(start+importJobBatchSize, len(items))
+
+		results, _ := session.AddBatch(ctx, items[start:end])
+		updateImportJob(job, session.Summary(), results)
+
+		var err error
+		cancelled, err = database.UpdateImportJobProgress(context.WithoutCancel(ctx), job)
+		if err != nil {
+			fmt.Printf("import worker: failed to save progress of job %s: %v\n", job.ID, err)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	interrupted := ctx.Err() != nil && job.Processed < len(items)
+	cancelled = cancelled && job.Processed < len(items)
+	if interrupted || cancelled {
+		session.Interrupt()
+	}
+
+	// A shutdown mid-job should still record what was imported.
+	job.OperationID = session.Finish(context.WithoutCancel(ctx)).OperationID
+
+	switch {
+	case interrupted:
+		job.Status = models.JobStatusFailed
+		job.Error = "interrupted by a server shutdown"
+	case cancelled:
+		job.Status = models.JobStatusCancelled
+	case len(items) > 0 && job.FailedCount == len(items):
+		job.Status = models.JobStatusFailed
+		job.Error = "no bookmarks could be imported"
+	default:
+		job.Status = models.JobStatusCompleted
+	}
+
+	if err := database.FinishImportJob(context.WithoutCancel(ctx), job); err != nil {
+		fmt.Printf("import worker: failed to finish job %s: %v\n", job.ID, err)
+	}
+}
+
+// updateImportJob copies the session counts to the job and collects the
+// failed items of the latest batch.
+func updateImportJob(job *models.ImportJob, summary models.ImportResponse, results []models.ImportItemResult) {
+	job.Processed += len(results)
+	job.ImportedCount = summary.ImportedCount
+	job.UpdatedCount = summary.UpdatedCount
+	job.DuplicateCount = summary.DuplicateCount
+	job.FailedCount = summary.FailedCount
+	job.AutoCategorized = summary.AutoCategorized
+
+	for _, result := range results {
+		if result.Outcome == models.ImportOutcomeFailed && len(job.Errors) < maxImportJobErrors {
+			job.Errors = append(job.Errors, result)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+	"twitter-bookmarks-api/ai"
+	"twitter-bookmarks-api/database"
+	"twitter-bookmarks-api/models"
+
+	"github.com/google/uuid"
+)
+
+// Limits on the AI suggestions of a dry run, which all run within one HTTP
+// request: at most maxPreviewCategorizations new bookmarks are sent, by
+// previewAIWorkers concurrent requests, and whatever has not come back after
+// previewAITimeout is left out.
+const (
+	maxPreviewCategorizations = 50
+	previewAIWorkers          = 4
+	previewAITimeout          = 20 * time.Second
+)
+
+var previewClasses = map[string]string{
+	models.ImportOutcomeInserted:  models.ImportPreviewNew,
+	models.ImportOutcomeUnchanged: models.ImportPreviewDuplicate,
+	models.ImportOutcomeUpdated:   models.ImportPreviewWouldUpdate,
+}
+
+// PreviewImport validates and classifies items the way ImportBookmarks
+// would import them, without writing anything, and lists the categories the
+// import would create. With aiCategories, and if the user enabled
+// auto-categorization, new bookmarks also get the categories the AI would
+// assign them.
+func PreviewImport(ctx context.Context, userID uuid.UUID, items []models.BookmarkImportItem, mode string, aiCategories bool) (*models.ImportPreview, error) {
+	preview := &models.ImportPreview{
+		DryRun:        true,
+		Mode:          mode,
+		NewCategories: []string{},
+		Items:         make([]models.ImportPreviewItem, len(items)),
+	}
+	if user, err := database.GetUserByID(ctx, userID); err == nil && user != nil {
+		preview.AutoCategorize = user.AutoCategorize
+	}
+
+	var bookmarks []*models.Bookmark
+	var pending []int
+	for i, item := range items {
+		preview.Items[i] = models.ImportPreviewItem{Index: i, TweetID: item.TweetID}
+
+		bookmark, fieldErrors := bookmarkFromImportItem(userID, item)
+		if len(fieldErrors) > 0 {
+			preview.Items[i].Class = models.ImportPreviewInvalid
+			preview.Items[i].Fields = fieldErrors
+			preview.InvalidCount++
+			continue
+		}
+		bookmarks = append(bookmarks, bookmark)
+		pending = append(pending, i)
+	}
+
+	outcomes, changes, err := database.PreviewImport(ctx, userID, bookmarks, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	var newBookmarks []int
+	for n, outcome := range outcomes {
+		item := &preview.Items[pending[n]]
+		item.Class = previewClasses[outcome]
+		item.Changes = changes[n]
+		if bookmarks[n].ID != uuid.Nil {
+			item.BookmarkID = &bookmarks[n].ID
+		}
+
+		switch item.Class {
+		case models.ImportPreviewNew:
+			preview.NewCount++
+			newBookmarks = append(newBookmarks, n)
+		case models.ImportPreviewWouldUpdate:
+			preview.WouldUpdateCount++
+		default:
+			preview.DuplicateCount++
+		}
+	}
+
+	categories, err := database.GetCategoriesByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	categoryNames := make([]string, len(categories))
+	known := make(map[string]bool, len(categories))
+	for i, cat := range categories {
+		categoryNames[i] = cat.Name
+		known[strings.ToLower(cat.Name)] = true
+	}
+
+	// Categories named in the items are created before AI categorization
+	// runs, so the AI gets to pick from them too.
+	for _, b := range bookmarks {
+		for _, cat := range b.Categories {
+			if key := strings.ToLower(cat.Name); !known[key] {
+				known[key] = true
+				categoryNames = append(categoryNames, cat.Name)
+				preview.NewCategories = append(preview.NewCategories, cat.Name)
+			}
+		}
+	}
+
+	if aiCategories && preview.AutoCategorize && len(newBookmarks) > 0 {
+		if len(newBookmarks) > maxPreviewCategorizations {
+			newBookmarks = newBookmarks[:maxPreviewCategorizations]
+			preview.AICategoriesLimited = true
+		}
+		previewAICategories(ctx, preview, bookmarks, pending, newBookmarks, categoryNames, known)
+	}
+
+	return preview, nil
+}
+
+// previewAICategories asks the AI for the categories of the given new
+// bookmarks, as CategorizeBookmarksForUser would, and records the ones that
+// would have to be created. The bookmarks are sent concurrently, so unlike a
+// real run the suggestions for one bookmark are not offered to the others.
+// Bookmarks still waiting at the deadline get no suggestions and mark the
+// preview as limited.
+func previewAICategories(ctx context.Context, preview *models.ImportPreview, bookmarks []*models.Bookmark, pending, newBookmarks []int, categoryNames []string, known map[string]bool) {
+	ctx, cancel := context.WithTimeout(ctx, previewAITimeout)
+	defer cancel()
+
+	suggestions := make([][]string, len(newBookmarks))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < previewAIWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				text := bookmarks[newBookmarks[i]].TweetText
+				if strings.TrimSpace(text) == "" || ctx.Err() != nil {
+					continue
+				}
+				if suggested, err := ai.CategorizeBookmark(ctx, text, categoryNames); err == nil {
+					suggestions[i] = suggested
+				}
+			}
+		}()
+	}
+	for i := range newBookmarks {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	if ctx.Err() != nil {
+		preview.AICategoriesLimited = true
+	}
+
+	for i, n := range newBookmarks {
+		item := &preview.Items[pending[n]]
+		for _, name := range suggestions[i] {
+			if name == "" {
+				continue
+			}
+			item.AICategories = append(item.AICategories, name)
+			if key := strings.ToLower(name); !known[key] {
+				known[key] = true
+				categoryNames = append(categoryNames, name)
+				preview.NewCategories = append(preview.NewCategories, name)
+			}
+		}
+	}
+}
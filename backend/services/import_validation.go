@@ -0,0 +1,179 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"twitter-bookmarks-api/langdetect"
+	"twitter-bookmarks-api/models"
+	"twitter-bookmarks-api/tweeturl"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxImportTweetIDLength     = 20
+	maxImportTweetTextLength   = 25000
+	maxImportDisplayNameLength = 100
+	maxImportURLLength         = 2048
+	maxImportMediaURLs         = 20
+	maxImportCategories        = 20
+	maxImportCategoryLength    = 100
+	// maxImportClockSkew is how far in the future a bookmark date may be
+	// before it is rejected.
+	maxImportClockSkew = 24 * time.Hour
+)
+
+// importTimeLayouts are the accepted bookmarked_at formats. time.RubyDate is
+// the format of created_at in X's API and data archive, e.g.
+// "Wed Oct 10 20:19:24 +0000 2018".
+var importTimeLayouts = []string{
+	time.RFC3339,
+	time.RubyDate,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+var (
+	tweetIDPattern  = regexp.MustCompile(`^[0-9]+$`)
+	usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_]{1,15}$`)
+)
+
+// bookmarkFromImportItem validates an import item and converts it into a
+// bookmark for userID. Problems are reported per field; the bookmark is only
+// usable when no field errors are returned.
+func bookmarkFromImportItem(userID uuid.UUID, item models.BookmarkImportItem) (*models.Bookmark, map[string]string) {
+	fieldErrors := make(map[string]string)
+
+	tweetID := strings.TrimSpace(item.TweetID)
+	switch {
+	case tweetID == "":
+		fieldErrors["tweet_id"] = "is required"
+	case len(tweetID) > maxImportTweetIDLength || !tweetIDPattern.MatchString(tweetID):
+		fieldErrors["tweet_id"] = "must be a numeric tweet id"
+	}
+
+	if utf8.RuneCountInString(item.TweetText) > maxImportTweetTextLength {
+		fieldErrors["tweet_text"] = fmt.Sprintf("must be at most %d characters", maxImportTweetTextLength)
+	}
+
+	username := strings.TrimPrefix(strings.TrimSpace(item.AuthorUsername), "@")
+	if username != "" && !usernamePattern.MatchString(username) {
+		fieldErrors["author_username"] = "must be 1-15 letters, digits or underscores"
+	}
+
+	if utf8.RuneCountInString(item.AuthorDisplayName) > maxImportDisplayNameLength {
+		fieldErrors["author_display_name"] = fmt.Sprintf("must be at most %d characters", maxImportDisplayNameLength)
+	}
+
+	tweetURL := strings.TrimSpace(item.TweetURL)
+	if tweetURL != "" {
+		if msg := validateTweetURL(tweetURL, tweetID); msg != "" {
+			fieldErrors["tweet_url"] = msg
+		}
+	}
+
+	if len(item.MediaURLs) > maxImportMediaURLs {
+		fieldErrors["media_urls"] = fmt.Sprintf("must have at most %d entries", maxImportMediaURLs)
+	}
+	for i, raw := range item.MediaURLs {
+		if !isHTTPURL(raw) {
+			fieldErrors[fmt.Sprintf("media_urls[%d]", i)] = "must be an http or https URL"
+		}
+	}
+
+	if len(item.Categories) > maxImportCategories {
+		fieldErrors["categories"] = fmt.Sprintf("must have at most %d entries", maxImportCategories)
+	}
+	var categories []models.Category
+	seen := make(map[string]bool, len(item.Categories))
+	for i, name := range item.Categories {
+		name = strings.TrimSpace(name)
+		switch {
+		case name == "":
+			fieldErrors[fmt.Sprintf("categories[%d]", i)] = "must not be empty"
+		case utf8.RuneCountInString(name) > maxImportCategoryLength:
+			fieldErrors[fmt.Sprintf("categories[%d]", i)] = fmt.Sprintf("must be at most %d characters", maxImportCategoryLength)
+		case !seen[strings.ToLower(name)]:
+			seen[strings.ToLower(name)] = true
+			categories = append(categories, models.Category{
+				Name:  name,
+				Color: getColorForCategory(name),
+				Icon:  getIconForCategory(name),
+			})
+		}
+	}
+
+	bookmarkedAt := time.Now()
+	if value := strings.TrimSpace(item.BookmarkedAt); value != "" {
+		parsed, ok := parseImportTime(value)
+		switch {
+		case !ok:
+			fieldErrors["bookmarked_at"] = "must be an RFC 3339 timestamp or X date such as \"Wed Oct 10 20:19:24 +0000 2018\""
+		case parsed.After(time.Now().Add(maxImportClockSkew)):
+			fieldErrors["bookmarked_at"] = "must not be in the future"
+		default:
+			bookmarkedAt = parsed
+		}
+	}
+
+	if len(fieldErrors) > 0 {
+		return nil, fieldErrors
+	}
+
+	return &models.Bookmark{
+		UserID:            userID,
+		TweetID:           tweetID,
+		TweetText:         item.TweetText,
+		AuthorUsername:    username,
+		AuthorDisplayName: item.AuthorDisplayName,
+		TweetURL:          tweetURL,
+		MediaURLs:         item.MediaURLs,
+		BookmarkedAt:      bookmarkedAt,
+		Lang:              langdetect.Detect(item.TweetText),
+		Categories:        categories,
+	}, nil
+}
+
+// validateTweetURL checks that raw is a link to a tweet on X and, when the
+// link names a tweet id, that it is tweetID.
+func validateTweetURL(raw, tweetID string) string {
+	if len(raw) > maxImportURLLength {
+		return fmt.Sprintf("must be at most %d characters", maxImportURLLength)
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "must be an http or https URL"
+	}
+	if !tweeturl.IsTweetHost(u.Hostname()) {
+		return "must be an x.com or twitter.com URL"
+	}
+	linkedID, _, ok := tweeturl.Parse(raw)
+	if !ok {
+		return "must link to a tweet"
+	}
+	if tweetID != "" && linkedID != tweetID {
+		return "does not match tweet_id"
+	}
+	return ""
+}
+
+func isHTTPURL(raw string) bool {
+	if len(raw) > maxImportURLLength {
+		return false
+	}
+	u, err := url.Parse(strings.TrimSpace(raw))
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func parseImportTime(value string) (time.Time, bool) {
+	for _, layout := range importTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"twitter-bookmarks-api/database"
+	"twitter-bookmarks-api/models"
+
+	"github.com/google/uuid"
+)
+
+// ImportBookmarks imports items for a user as one batch, then runs AI
+// categorization on the new bookmarks if the user enabled it and records the
+// import in the operation log. Items that fail validation are reported with
+// per-field errors and left out. The remaining items are applied atomically:
+// if saving fails, every one of them is reported as failed and nothing is
+// written. It returns database.ErrImportInProgress while another import of
+// the user is running.
+func ImportBookmarks(ctx context.Context, userID uuid.UUID, items []models.BookmarkImportItem, mode, source string) (*models.ImportResponse, error) {
+	session, err := NewImportSession(ctx, userID, mode, source, false)
+	if err != nil {
+		return nil, err
+	}
+	results, err := session.AddBatch(ctx, items)
+	response := session.Finish(ctx)
+	response.Results = results
+	if err != nil {
+		response.Message = "Import failed, no bookmarks were saved"
+	}
+	return response, nil
+}
+
+// maxSyncHeadTweetIDs is how many of an import's first tweets its sync
+// session keeps.
+const maxSyncHeadTweetIDs = 20
+
+// ImportSession imports a user's bookmarks in consecutive batches, each
+// applied atomically, and records them as a single operation and sync
+// session when finished. Only counts are kept between batches, so a session
+// can handle any number of items. A session holds the user's import lock
+// until Finish, so a user's imports run one at a time.
+type ImportSession struct {
+	lock           *database.ImportLock
+	userID         uuid.UUID
+	mode           string
+	autoCategorize bool
+	summary        models.ImportResponse
+	inverse        models.OperationInverse
+	sync           models.SyncSession
+}
+
+// NewImportSession starts an import for userID from source. New bookmarks
+// are categorized with AI as they are imported if the user enabled it. With
+// wait it waits for another import of the user to finish; without, it
+// returns database.ErrImportInProgress right away. Finish must be called to
+// let the next import start.
+func NewImportSession(ctx context.Context, userID uuid.UUID, mode, source string, wait bool) (*ImportSession, error) {
+	lock, err := database.LockImports(ctx, userID, wait)
+	if err != nil {
+		return nil, err
+	}
+	session := &ImportSession{
+		lock:    lock,
+		userID:  userID,
+		mode:    mode,
+		summary: models.ImportResponse{Message: "Import completed", Mode: mode},
+		sync:    models.SyncSession{Source: source, Complete: true, StartedAt: time.Now()},
+	}
+	if user, err := database.GetUserByID(ctx, userID); err == nil && user != nil {
+		session.autoCategorize = user.AutoCategorize
+	}
+	return session, nil
+}
+
+// Processed returns the number of items handed to the session so far.
+func (s *ImportSession) Processed() int {
+	return s.summary.ImportedCount + s.summary.UpdatedCount + s.summary.DuplicateCount + s.summary.FailedCount
+}
+
+// Summary returns the counts so far.
+func (s *ImportSession) Summary() models.ImportResponse {
+	return s.summary
+}
+
+// AddFailure counts an item that could not be handed to AddBatch, such as
+// a line of a streamed import that is not valid JSON.
+func (s *ImportSession) AddFailure() {
+	s.summary.FailedCount++
+}
+
+// Interrupt marks the session as stopped before it received all items, as
+// when a streamed upload breaks off or a job is cancelled.
+func (s *ImportSession) Interrupt() {
+	s.sync.Complete = false
+}
+
+// AddBatch validates and saves items, along with their category
+// assignments, and returns one result per item, with indexes continuing
+// from earlier batches. When saving fails, the valid items are reported as
+// failed and the error is returned.
+func (s *ImportSession) AddBatch(ctx context.Context, items []models.BookmarkImportItem) ([]models.ImportItemResult, error) {
+	offset := s.Processed()
+	results := make([]models.ImportItemResult, len(items))
+
+	var bookmarks []*models.Bookmark
+	var pending []int
+	for i, item := range items {
+		results[i] = models.ImportItemResult{Index: offset + i, TweetID: item.TweetID}
+
+		bookmark, fieldErrors := bookmarkFromImportItem(s.userID, item)
+		if len(fieldErrors) > 0 {
+			results[i].Outcome = models.ImportOutcomeFailed
+			results[i].Error = "invalid bookmark"
+			results[i].Fields = fieldErrors
+			continue
+		}
+
+		bookmarks = append(bookmarks, bookmark)
+		pending = append(pending, i)
+	}
+
+	imported, err := database.ImportBookmarks(ctx, s.lock, bookmarks, s.mode)
+	if err != nil {
+		fmt.Printf("import of %d bookmarks failed: %v\n", len(bookmarks), err)
+		for _, i := range pending {
+			results[i].Outcome = models.ImportOutcomeFailed
+			results[i].Error = "failed to save bookmark"
+		}
+		imported = &database.ImportResult{}
+		s.sync.Complete = false
+	}
+	for _, b := range bookmarks {
+		if len(s.sync.HeadTweetIDs) >= maxSyncHeadTweetIDs {
+			break
+		}
+		s.sync.HeadTweetIDs = append(s.sync.HeadTweetIDs, b.TweetID)
+	}
+	s.summary.CategoriesAssigned += len(imported.Assignments)
+	s.inverse.RemoveAssignments = append(s.inverse.RemoveAssignments, imported.Assignments...)
+	s.inverse.TrashCategories = append(s.inverse.TrashCategories, imported.CreatedCategories...)
+	s.inverse.BookmarkEdits = append(s.inverse.BookmarkEdits, imported.Edits...)
+
+	var newBookmarks []models.Bookmark
+	for n, outcome := range imported.Outcomes {
+		result := &results[pending[n]]
+		result.Outcome = outcome
+		result.BookmarkID = &bookmarks[n].ID
+		if outcome == models.ImportOutcomeInserted {
+			newBookmarks = append(newBookmarks, *bookmarks[n])
+			s.inverse.TrashBookmarks = append(s.inverse.TrashBookmarks, bookmarks[n].ID)
+		}
+	}
+
+	for _, result := range results {
+		switch result.Outcome {
+		case models.ImportOutcomeInserted:
+			s.summary.ImportedCount++
+		case models.ImportOutcomeUpdated:
+			s.summary.UpdatedCount++
+		case models.ImportOutcomeUnchanged:
+			s.summary.DuplicateCount++
+		default:
+			s.summary.FailedCount++
+		}
+	}
+
+	if s.autoCategorize && len(newBookmarks) > 0 {
+		result, err := CategorizeBookmarksForUser(ctx, s.userID, newBookmarks)
+		if err != nil {
+			fmt.Printf("auto categorize failed: %v\n", err)
+		} else {
+			s.summary.AutoCategorized += result.Categorized
+			inverse := result.Inverse()
+			s.inverse.RemoveAssignments = append(s.inverse.RemoveAssignments, inverse.RemoveAssignments...)
+			s.inverse.TrashCategories = append(s.inverse.TrashCategories, inverse.TrashCategories...)
+		}
+	}
+
+	return results, err
+}
+
+// Finish records the session's new bookmarks, the previous content of the
+// bookmarks it updated, and its category and AI assignments in the operation
+// log so that they can be undone together,
+// adds the import to the user's sync history and returns the final counts.
+func (s *ImportSession) Finish(ctx context.Context) *models.ImportResponse {
+	s.lock.Release(ctx)
+
+	response := s.summary
+	if len(s.inverse.TrashBookmarks) > 0 || len(s.inverse.RemoveAssignments) > 0 || len(s.inverse.BookmarkEdits) > 0 {
+		operationID, err := database.RecordOperation(ctx, s.userID, models.OperationImport, s.inverse)
+		if err != nil {
+			fmt.Printf("failed to record %s operation: %v\n", models.OperationImport, err)
+		} else {
+			response.OperationID = &operationID
+		}
+	}
+
+	if s.Processed() > 0 {
+		s.sync.ItemCount = s.Processed()
+		s.sync.ImportedCount = response.ImportedCount
+		s.sync.UpdatedCount = response.UpdatedCount
+		s.sync.DuplicateCount = response.DuplicateCount
+		s.sync.FailedCount = response.FailedCount
+		s.sync.OperationID = response.OperationID
+		if err := database.RecordSyncSession(ctx, s.userID, &s.sync); err != nil {
+			fmt.Printf("failed to record sync session: %v\n", err)
+		}
+	}
+	return &response
+}
@@ -0,0 +1,19 @@
+package services
+
+import "time"
+
+const defaultUndoWindow = 10 * time.Minute
+
+// UndoWindow returns how long after an operation it can still be undone,
+// configured with UNDO_WINDOW.
+func UndoWindow() time.Duration {
+	return durationFromEnv("UNDO_WINDOW", defaultUndoWindow)
+}
+
+const defaultIdempotencyKeyTTL = 24 * time.Hour
+
+// IdempotencyKeyTTL returns how long a response stays stored under its
+// Idempotency-Key for replay, configured with IDEMPOTENCY_KEY_TTL.
+func IdempotencyKeyTTL() time.Duration {
+	return durationFromEnv("IDEMPOTENCY_KEY_TTL", defaultIdempotencyKeyTTL)
+}
@@ -0,0 +1,298 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+	"twitter-bookmarks-api/database"
+	"twitter-bookmarks-api/models"
+)
+
+const (
+	defaultStatusCheckInterval = time.Hour
+	defaultStatusCheckMaxAge   = 24 * time.Hour
+	statusCheckBatchSize       = 100
+	statusCheckRequestTimeout  = 10 * time.Second
+)
+
+var linkPattern = regexp.MustCompile(`https?://[^\s<>"]+`)
+
+// shortenerHosts redirect by design, so their first hop is the link's
+// expansion rather than a sign that the link has moved.
+var shortenerHosts = map[string]bool{
+	"t.co":    true,
+	"bit.ly":  true,
+	"buff.ly": true,
+	"ow.ly":   true,
+	"lnkd.in": true,
+}
+
+var statusCheckClient = &http.Client{Timeout: statusCheckRequestTimeout}
+
+var errInternalAddress = errors.New("link resolves to a non-public address")
+
+// sharedAddressSpace is the carrier-grade NAT range, which netip does not
+// count as private.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// linkCheckTransport only connects to public addresses. Links come from
+// tweet text, so without this anyone could make the server request hosts on
+// its own network. The check runs on the resolved address of every
+// connection, which covers redirects and DNS names pointing inward.
+var linkCheckTransport = &http.Transport{
+	DialContext: (&net.Dialer{
+		Timeout: statusCheckRequestTimeout,
+		Control: rejectInternalAddress,
+	}).DialContext,
+	TLSHandshakeTimeout: statusCheckRequestTimeout,
+	MaxIdleConns:        100,
+	IdleConnTimeout:     90 * time.Second,
+}
+
+func rejectInternalAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() || sharedAddressSpace.Contains(addr) {
+		return errInternalAddress
+	}
+	return nil
+}
+
+// StartStatusChecker periodically verifies that bookmarked tweets and the
+// links in them still resolve, until ctx is cancelled. STATUS_CHECK_INTERVAL
+// sets how often a pass runs and STATUS_CHECK_MAX_AGE how old a check has to
+// be before a bookmark is checked again.
+func StartStatusChecker(ctx context.Context) {
+	interval := durationFromEnv("STATUS_CHECK_INTERVAL", defaultStatusCheckInterval)
+	maxAge := durationFromEnv("STATUS_CHECK_MAX_AGE", defaultStatusCheckMaxAge)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			runStatusCheckPass(ctx, maxAge)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func runStatusCheckPass(ctx context.Context, maxAge time.Duration) {
+	bookmarks, err := database.ClaimBookmarksForStatusCheck(ctx, maxAge, statusCheckBatchSize)
+	if err != nil {
+		fmt.Printf("status check: failed to load bookmarks: %v\n", err)
+		return
+	}
+
+	for _, bookmark := range bookmarks {
+		if ctx.Err() != nil {
+			return
+		}
+
+		status, links, err := CheckBookmarkStatus(ctx, bookmark)
+		if err != nil {
+			// Transient failures (rate limits, timeouts) leave the previous
+			// status in place; the bookmark is retried once its attempt is
+			// maxAge old.
+			fmt.Printf("status check: bookmark %s: %v\n", bookmark.ID, err)
+			continue
+		}
+
+		if err := database.UpdateBookmarkStatus(ctx, bookmark.ID, status, links); err != nil {
+			fmt.Printf("status check: failed to save bookmark %s: %v\n", bookmark.ID, err)
+		}
+	}
+}
+
+// CheckBookmarkStatus resolves a bookmark's tweet and every link in its text,
+// returning the overall status and the per-link results. A tweet that is
+// gone wins over link problems, and a dead link wins over a redirected one.
+// A link that cannot be reached at all keeps the result of its previous
+// check and is tried again next time.
+func CheckBookmarkStatus(ctx context.Context, bookmark models.Bookmark) (string, []models.BookmarkLink, error) {
+	tweetStatus, err := checkTweetStatus(ctx, bookmark)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var links []models.BookmarkLink
+	var previous map[string]models.BookmarkLink
+	status := tweetStatus
+	for _, raw := range extractLinks(bookmark.TweetText) {
+		link, err := checkLink(ctx, raw)
+		if err != nil {
+			if previous == nil {
+				if previous, err = previousLinks(ctx, bookmark); err != nil {
+					return "", nil, err
+				}
+			}
+			prev, ok := previous[raw]
+			if !ok {
+				prev = models.BookmarkLink{URL: raw, ExpandedURL: raw, Status: models.BookmarkStatusUnchecked, CheckedAt: time.Now()}
+			}
+			link = prev
+		}
+		links = append(links, link)
+
+		switch {
+		case link.Status == models.BookmarkStatusNotFound && (status == models.BookmarkStatusOK || status == models.BookmarkStatusRedirected):
+			status = link.Status
+		case link.Status == models.BookmarkStatusRedirected && status == models.BookmarkStatusOK:
+			status = link.Status
+		}
+	}
+
+	return status, links, nil
+}
+
+func previousLinks(ctx context.Context, bookmark models.Bookmark) (map[string]models.BookmarkLink, error) {
+	links, err := database.GetBookmarkLinks(ctx, bookmark.ID)
+	if err != nil {
+		return nil, err
+	}
+	byURL := make(map[string]models.BookmarkLink, len(links))
+	for _, link := range links {
+		byURL[link.URL] = link
+	}
+	return byURL, nil
+}
+
+// checkTweetStatus asks the public oEmbed endpoint about the tweet, which
+// answers 404 for deleted tweets and 403 for tweets from protected accounts.
+func checkTweetStatus(ctx context.Context, bookmark models.Bookmark) (string, error) {
+	tweetURL := bookmark.TweetURL
+	if tweetURL == "" {
+		tweetURL = "https://twitter.com/i/status/" + bookmark.TweetID
+	}
+
+	endpoint := "https://publish.twitter.com/oembed?omit_script=true&url=" + url.QueryEscape(tweetURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := statusCheckClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach oEmbed endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return models.BookmarkStatusOK, nil
+	case http.StatusNotFound, http.StatusGone:
+		return models.BookmarkStatusDeleted, nil
+	case http.StatusForbidden:
+		return models.BookmarkStatusProtected, nil
+	default:
+		return "", fmt.Errorf("oEmbed endpoint returned status %d", resp.StatusCode)
+	}
+}
+
+// checkLink follows a link's redirects. The first hop out of a URL shortener
+// is recorded as the expanded URL; landing on a different host after that
+// marks the link as redirected. Only a 404 or 410 marks the link as not
+// found; when no response comes back at all the error is returned instead.
+func checkLink(ctx context.Context, raw string) (models.BookmarkLink, error) {
+	link := models.BookmarkLink{URL: raw, ExpandedURL: raw, CheckedAt: time.Now()}
+
+	start, err := url.Parse(raw)
+	if err != nil {
+		return link, err
+	}
+
+	var hops []*url.URL
+	client := &http.Client{
+		Transport: linkCheckTransport,
+		Timeout:   statusCheckRequestTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return http.ErrUseLastResponse
+			}
+			hops = append(hops, req.URL)
+			return nil
+		},
+	}
+
+	resp, err := requestLink(ctx, client, http.MethodHead, raw)
+	if err == nil && resp.StatusCode == http.StatusMethodNotAllowed {
+		resp.Body.Close()
+		hops = nil
+		resp, err = requestLink(ctx, client, http.MethodGet, raw)
+	}
+	if err != nil {
+		return link, err
+	}
+	resp.Body.Close()
+
+	if shortenerHosts[strings.ToLower(start.Hostname())] && len(hops) > 0 {
+		start = hops[0]
+		hops = hops[1:]
+		link.ExpandedURL = start.String()
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		link.Status = models.BookmarkStatusNotFound
+	case len(hops) > 0 && !strings.EqualFold(hops[len(hops)-1].Hostname(), start.Hostname()):
+		link.Status = models.BookmarkStatusRedirected
+	default:
+		link.Status = models.BookmarkStatusOK
+	}
+	return link, nil
+}
+
+func requestLink(ctx context.Context, client *http.Client, method, target string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
+
+func extractLinks(text string) []string {
+	seen := make(map[string]bool)
+	var links []string
+	for _, match := range linkPattern.FindAllString(text, -1) {
+		match = strings.TrimRight(match, ".,;:!?)…")
+		if !seen[match] {
+			seen[match] = true
+			links = append(links, match)
+		}
+	}
+	return links
+}
+
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		fmt.Printf("invalid %s %q, using %v\n", key, value, fallback)
+		return fallback
+	}
+	return d
+}
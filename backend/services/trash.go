@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+	"twitter-bookmarks-api/database"
+)
+
+const (
+	defaultTrashRetentionDays = 30
+	trashPurgeInterval        = time.Hour
+)
+
+// TrashRetentionDays returns how many days deleted items stay in the trash,
+// configured with TRASH_RETENTION_DAYS.
+func TrashRetentionDays() int {
+	value := os.Getenv("TRASH_RETENTION_DAYS")
+	if value == "" {
+		return defaultTrashRetentionDays
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 1 {
+		fmt.Printf("invalid TRASH_RETENTION_DAYS %q, using %d\n", value, defaultTrashRetentionDays)
+		return defaultTrashRetentionDays
+	}
+	return days
+}
+
+// StartTrashPurger permanently deletes items that outlived the trash
+// retention period, checking once an hour until ctx is cancelled.
+func StartTrashPurger(ctx context.Context) {
+	retention := time.Duration(TrashRetentionDays()) * 24 * time.Hour
+
+	go func() {
+		ticker := time.NewTicker(trashPurgeInterval)
+		defer ticker.Stop()
+
+		for {
+			bookmarks, categories, err := database.PurgeTrash(ctx, time.Now().Add(-retention))
+			if err != nil {
+				fmt.Printf("trash purge failed: %v\n", err)
+			} else if bookmarks > 0 || categories > 0 {
+				fmt.Printf("trash purge: removed %d bookmarks and %d categories\n", bookmarks, categories)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
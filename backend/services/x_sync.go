@@ -0,0 +1,454 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"twitter-bookmarks-api/auth"
+	"twitter-bookmarks-api/database"
+	"twitter-bookmarks-api/models"
+	"twitter-bookmarks-api/tweeturl"
+
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+)
+
+const (
+	defaultXAPIBaseURL   = "https://api.twitter.com"
+	defaultXSyncInterval = 6 * time.Hour
+	xSyncPollInterval    = time.Minute
+	xSyncPageSize        = 100
+	// xRateLimitFallback is how long to wait after a 429 that does not say
+	// when the limit resets; X's rate limit windows are 15 minutes.
+	xRateLimitFallback = 15 * time.Minute
+)
+
+var (
+	errXRateLimited  = errors.New("X API rate limit reached")
+	errXUnauthorized = errors.New("X authorization expired, sign in with X again")
+)
+
+// xSyncEnabled is set by StartXSyncWorker. Without the worker, X tokens
+// are not stored and syncs cannot be requested.
+var xSyncEnabled bool
+
+// XSyncEnabled reports whether X bookmarks are synced on this server.
+func XSyncEnabled() bool {
+	return xSyncEnabled
+}
+
+// xSyncWake lets RequestXSync start the worker without waiting for the next
+// poll.
+var xSyncWake = make(chan struct{}, 1)
+
+// RequestXSync schedules a sync of the user's X bookmarks as soon as
+// possible.
+func RequestXSync(ctx context.Context, userID uuid.UUID) (*models.XSync, error) {
+	sync, err := database.RequestXSync(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case xSyncWake <- struct{}{}:
+	default:
+	}
+	return sync, nil
+}
+
+// StartXSyncWorker syncs the bookmarks of users who signed in with X, until
+// ctx is cancelled. Each user is synced every X_SYNC_INTERVAL, and sooner
+// when they ask for it. Requests go to X_API_BASE_URL, which can point to a
+// mock server in development. Each poll also resumes syncs whose worker
+// stopped heartbeating; syncs other instances are running are left alone.
+// It must be called before the server starts handling requests.
+func StartXSyncWorker(ctx context.Context) {
+	interval := durationFromEnv("X_SYNC_INTERVAL", defaultXSyncInterval)
+	xSyncEnabled = true
+
+	go func() {
+		ticker := time.NewTicker(xSyncPollInterval)
+		defer ticker.Stop()
+
+		for {
+			if n, err := database.ResetAbandonedXSyncs(ctx, leaseTimeout); err != nil {
+				fmt.Printf("x sync: failed to reset abandoned syncs: %v\n", err)
+			} else if n > 0 {
+				fmt.Printf("x sync: resuming %d abandoned syncs\n", n)
+			}
+			for ctx.Err() == nil {
+				sync, err := database.ClaimDueXSync(ctx)
+				if err != nil {
+					fmt.Printf("x sync: failed to claim sync: %v\n", err)
+					break
+				}
+				if sync == nil {
+					break
+				}
+				runXSync(ctx, sync, interval)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-xSyncWake:
+			}
+		}
+	}()
+}
+
+// runXSync pages through a user's X bookmarks, newest first, and imports
+// each page through an ImportSession. The cursor is saved after every page,
+// so a run stopped by a rate limit or a restart resumes where it left off.
+// Once a full pass has completed, later runs stop at the first page without
+// new bookmarks.
+func runXSync(ctx context.Context, sync *models.XSync, interval time.Duration) {
+	stopHeartbeat := startHeartbeat(ctx, "x sync of user "+sync.UserID.String(), func(ctx context.Context) error {
+		return database.TouchXSync(ctx, sync.UserID)
+	})
+	defer stopHeartbeat()
+
+	sync.Error = ""
+	sync.OperationID = nil
+	sync.NextSyncAt = time.Now().Add(interval)
+
+	token, user, err := loadXSyncUser(ctx, sync.UserID)
+	if err != nil {
+		sync.Status = models.XSyncStatusFailed
+		sync.Error = err.Error()
+		finishXSync(ctx, sync)
+		return
+	}
+
+	session, err := NewImportSession(ctx, sync.UserID, models.ImportModeUpdate, models.ImportSourceXAPI, true)
+	if err != nil {
+		fmt.Printf("x sync: user %s: %v\n", sync.UserID, err)
+		sync.Status = models.XSyncStatusFailed
+		sync.Error = "failed to start import"
+		finishXSync(ctx, sync)
+		return
+	}
+	source := &savingTokenSource{ctx: ctx, userID: sync.UserID, source: auth.TokenSource(ctx, token), last: token.AccessToken}
+	client := oauth2.NewClient(ctx, source)
+
+	incremental := sync.Cursor == "" && sync.LastSyncedAt != nil
+	sync.Status = models.XSyncStatusIdle
+	for ctx.Err() == nil {
+		page, limit, err := fetchXBookmarks(ctx, client, user.TwitterID, sync.Cursor)
+		if errors.Is(err, errXRateLimited) {
+			sync.Status = models.XSyncStatusRateLimited
+			sync.NextSyncAt = limit.reset
+			break
+		}
+		if err != nil {
+			fmt.Printf("x sync: user %s: %v\n", sync.UserID, err)
+			sync.Status = models.XSyncStatusFailed
+			sync.Error = "failed to fetch bookmarks from X"
+			if errors.Is(err, errXUnauthorized) {
+				sync.Error = errXUnauthorized.Error()
+			}
+			break
+		}
+
+		results, err := session.AddBatch(ctx, page.items())
+		if err != nil {
+			sync.Status = models.XSyncStatusFailed
+			sync.Error = "failed to save bookmarks"
+			break
+		}
+
+		sync.Cursor = page.Meta.NextToken
+		caughtUp := incremental && !anyInserted(results)
+		if sync.Cursor == "" || caughtUp {
+			sync.Cursor = ""
+			now := time.Now()
+			sync.LastSyncedAt = &now
+			break
+		}
+		if err := database.SaveXSyncCursor(ctx, sync.UserID, sync.Cursor); err != nil {
+			fmt.Printf("x sync: user %s: failed to save cursor: %v\n", sync.UserID, err)
+		}
+		if limit.known && limit.remaining == 0 {
+			sync.Status = models.XSyncStatusRateLimited
+			sync.NextSyncAt = limit.reset
+			break
+		}
+	}
+	if ctx.Err() != nil && sync.Cursor != "" {
+		// Shut down mid-sync: pick up from the cursor on the next start.
+		sync.NextSyncAt = time.Now()
+	}
+	if sync.Cursor != "" {
+		session.Interrupt()
+	}
+
+	response := session.Finish(context.WithoutCancel(ctx))
+	sync.ImportedCount = response.ImportedCount
+	sync.UpdatedCount = response.UpdatedCount
+	sync.FailedCount = response.FailedCount
+	sync.OperationID = response.OperationID
+
+	finishXSync(ctx, sync)
+}
+
+func finishXSync(ctx context.Context, sync *models.XSync) {
+	if err := database.FinishXSync(context.WithoutCancel(ctx), sync); err != nil {
+		fmt.Printf("x sync: failed to finish sync of user %s: %v\n", sync.UserID, err)
+	}
+}
+
+func loadXSyncUser(ctx context.Context, userID uuid.UUID) (*oauth2.Token, *models.User, error) {
+	stored, err := database.GetXToken(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	user, err := database.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, errors.New("user not found")
+	}
+
+	token := &oauth2.Token{
+		AccessToken:  stored.AccessToken,
+		RefreshToken: stored.RefreshToken,
+		TokenType:    stored.TokenType,
+		Expiry:       stored.Expiry,
+	}
+	return token, user, nil
+}
+
+// savingTokenSource stores a token as soon as source refreshes it. X
+// invalidates the old refresh token on refresh, so a sync that dies before
+// saving the new one would leave the user disconnected.
+type savingTokenSource struct {
+	ctx    context.Context
+	userID uuid.UUID
+	source oauth2.TokenSource
+	mu     sync.Mutex
+	last   string
+}
+
+func (s *savingTokenSource) Token() (*oauth2.Token, error) {
+	token, err := s.source.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if token.AccessToken != s.last {
+		if err := database.UpdateXToken(context.WithoutCancel(s.ctx), s.userID, xTokenFromOAuth(token)); err != nil {
+			fmt.Printf("x sync: user %s: failed to save refreshed token: %v\n", s.userID, err)
+		} else {
+			s.last = token.AccessToken
+		}
+	}
+	return token, nil
+}
+
+func xTokenFromOAuth(token *oauth2.Token) models.XToken {
+	return models.XToken{
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		TokenType:    token.TokenType,
+		Expiry:       token.Expiry,
+	}
+}
+
+func anyInserted(results []models.ImportItemResult) bool {
+	for _, result := range results {
+		if result.Outcome == models.ImportOutcomeInserted {
+			return true
+		}
+	}
+	return false
+}
+
+func xAPIBaseURL() string {
+	if base := os.Getenv("X_API_BASE_URL"); base != "" {
+		return strings.TrimSuffix(base, "/")
+	}
+	return defaultXAPIBaseURL
+}
+
+// xRateLimit is what the x-rate-limit-* response headers say about the
+// requests left in the current window.
+type xRateLimit struct {
+	known     bool
+	remaining int
+	reset     time.Time
+}
+
+func parseXRateLimit(header http.Header) xRateLimit {
+	limit := xRateLimit{reset: time.Now().Add(xRateLimitFallback)}
+	if seconds, err := strconv.ParseInt(header.Get("x-rate-limit-reset"), 10, 64); err == nil {
+		limit.reset = time.Unix(seconds, 0)
+	}
+	if remaining, err := strconv.Atoi(header.Get("x-rate-limit-remaining")); err == nil {
+		limit.known = true
+		limit.remaining = remaining
+	}
+	return limit
+}
+
+// fetchXBookmarks requests one page of the X API v2 bookmarks endpoint. A
+// 429 response returns errXRateLimited with the time the limit resets.
+func fetchXBookmarks(ctx context.Context, client *http.Client, twitterID, cursor string) (*xBookmarksPage, xRateLimit, error) {
+	query := url.Values{}
+	query.Set("max_results", strconv.Itoa(xSyncPageSize))
+	query.Set("expansions", "author_id,attachments.media_keys")
+	query.Set("tweet.fields", "created_at,entities,note_tweet,attachments")
+	query.Set("user.fields", "username,name")
+	query.Set("media.fields", "url,preview_image_url,type")
+	if cursor != "" {
+		query.Set("pagination_token", cursor)
+	}
+	endpoint := xAPIBaseURL() + "/2/users/" + url.PathEscape(twitterID) + "/bookmarks?" + query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, xRateLimit{}, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		// The token could not be refreshed: the user revoked access or the
+		// refresh token expired.
+		var retrieveErr *oauth2.RetrieveError
+		if errors.As(err, &retrieveErr) {
+			return nil, xRateLimit{}, fmt.Errorf("%w: %v", errXUnauthorized, err)
+		}
+		return nil, xRateLimit{}, err
+	}
+	defer resp.Body.Close()
+
+	limit := parseXRateLimit(resp.Header)
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusTooManyRequests:
+		return nil, limit, errXRateLimited
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, limit, fmt.Errorf("%w: status %d", errXUnauthorized, resp.StatusCode)
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, limit, fmt.Errorf("X API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	page := &xBookmarksPage{}
+	if err := json.NewDecoder(resp.Body).Decode(page); err != nil {
+		return nil, limit, fmt.Errorf("invalid X API response: %w", err)
+	}
+	return page, limit, nil
+}
+
+type xBookmarksPage struct {
+	Data     []xTweet `json:"data"`
+	Includes struct {
+		Users []struct {
+			ID       string `json:"id"`
+			Name     string `json:"name"`
+			Username string `json:"username"`
+		} `json:"users"`
+		Media []struct {
+			MediaKey        string `json:"media_key"`
+			URL             string `json:"url"`
+			PreviewImageURL string `json:"preview_image_url"`
+		} `json:"media"`
+	} `json:"includes"`
+	Meta struct {
+		ResultCount int    `json:"result_count"`
+		NextToken   string `json:"next_token"`
+	} `json:"meta"`
+}
+
+type xTweet struct {
+	ID        string    `json:"id"`
+	Text      string    `json:"text"`
+	AuthorID  string    `json:"author_id"`
+	Entities  xEntities `json:"entities"`
+	NoteTweet *struct {
+		Text     string    `json:"text"`
+		Entities xEntities `json:"entities"`
+	} `json:"note_tweet"`
+	Attachments struct {
+		MediaKeys []string `json:"media_keys"`
+	} `json:"attachments"`
+}
+
+type xEntities struct {
+	URLs []struct {
+		URL         string `json:"url"`
+		ExpandedURL string `json:"expanded_url"`
+		MediaKey    string `json:"media_key"`
+	} `json:"urls"`
+}
+
+// items converts a page to import items, using the full text of long posts,
+// expanding t.co links and resolving authors and media from the includes.
+// The API does not say when a tweet was bookmarked, so bookmarked_at is left
+// for the import to fill in.
+func (p *xBookmarksPage) items() []models.BookmarkImportItem {
+	type author struct{ username, name string }
+	authors := make(map[string]author, len(p.Includes.Users))
+	for _, u := range p.Includes.Users {
+		authors[u.ID] = author{u.Username, u.Name}
+	}
+	media := make(map[string]string, len(p.Includes.Media))
+	for _, m := range p.Includes.Media {
+		if m.URL != "" {
+			media[m.MediaKey] = m.URL
+		} else if m.PreviewImageURL != "" {
+			media[m.MediaKey] = m.PreviewImageURL
+		}
+	}
+
+	items := make([]models.BookmarkImportItem, 0, len(p.Data))
+	for _, t := range p.Data {
+		text, entities := t.Text, t.Entities
+		if t.NoteTweet != nil && t.NoteTweet.Text != "" {
+			text, entities = t.NoteTweet.Text, t.NoteTweet.Entities
+		}
+		// The API keeps the HTML escaping of &, < and >.
+		text = html.UnescapeString(text)
+		for _, u := range entities.URLs {
+			switch {
+			case u.URL == "":
+			case u.MediaKey != "":
+				text = strings.ReplaceAll(text, u.URL, "")
+			case u.ExpandedURL != "":
+				text = strings.ReplaceAll(text, u.URL, u.ExpandedURL)
+			}
+		}
+
+		var mediaURLs []string
+		for _, key := range t.Attachments.MediaKeys {
+			if mediaURL, ok := media[key]; ok {
+				mediaURLs = append(mediaURLs, mediaURL)
+			}
+		}
+
+		a := authors[t.AuthorID]
+		item := models.BookmarkImportItem{
+			TweetID:           t.ID,
+			TweetText:         strings.TrimSpace(text),
+			AuthorUsername:    a.username,
+			AuthorDisplayName: a.name,
+			TweetURL:          tweeturl.Format(a.username, t.ID),
+			MediaURLs:         mediaURLs,
+		}
+		items = append(items, item)
+	}
+	return items
+}
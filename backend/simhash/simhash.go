@@ -0,0 +1,69 @@
+// Package simhash computes 64-bit SimHash fingerprints of short texts.
+// Texts that differ only slightly (a changed word, added hashtags, different
+// punctuation) get fingerprints that differ in only a few bits.
+package simhash
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// MinTokens is the number of words a text needs for its fingerprint to be
+// meaningful; shorter texts collide too easily.
+const MinTokens = 5
+
+var noisePattern = regexp.MustCompile(`https?://\S+|[@#]\w+`)
+
+// Normalize lowercases text and strips links, mentions, hashtags and
+// punctuation, returning the remaining words.
+func Normalize(text string) []string {
+	text = strings.ToLower(noisePattern.ReplaceAllString(text, " "))
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Fingerprint returns the SimHash of text's words and word pairs, and
+// whether the text had enough words to fingerprint.
+func Fingerprint(text string) (uint64, bool) {
+	tokens := Normalize(text)
+	if len(tokens) < MinTokens {
+		return 0, false
+	}
+
+	var weights [64]int
+	add := func(feature string) {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		for i := 0; i < 64; i++ {
+			if sum&(1<<uint(i)) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+	for i, token := range tokens {
+		add(token)
+		if i > 0 {
+			add(tokens[i-1] + " " + token)
+		}
+	}
+
+	var fingerprint uint64
+	for i, w := range weights {
+		if w > 0 {
+			fingerprint |= 1 << uint(i)
+		}
+	}
+	return fingerprint, true
+}
+
+// Distance returns the number of bits in which two fingerprints differ.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
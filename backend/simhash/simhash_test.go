@@ -0,0 +1,94 @@
+package simhash
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b uint64
+		want int
+	}{
+		{"equal", 0xDEADBEEF, 0xDEADBEEF, 0},
+		{"one bit", 0, 1, 1},
+		{"top bit", 0, 1 << 63, 1},
+		{"all bits", 0, ^uint64(0), 64},
+		{"symmetric", 0b1011, 0b0110, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Distance(tt.a, tt.b); got != tt.want {
+				t.Errorf("Distance(%#x, %#x) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+			if got := Distance(tt.b, tt.a); got != tt.want {
+				t.Errorf("Distance(%#x, %#x) = %d, want %d", tt.b, tt.a, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Hello, World!", []string{"hello", "world"}},
+		{"Read this https://example.com/a?b=c now", []string{"read", "this", "now"}},
+		{"@jack says #golang 2.0 rocks", []string{"says", "2", "0", "rocks"}},
+		{"Año ñandú", []string{"año", "ñandú"}},
+		{"!!! ...", []string{}},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	const base = "The quick brown fox jumps over the lazy dog near the river bank today"
+
+	tests := []struct {
+		name        string
+		a, b        string
+		maxDistance int
+		minDistance int
+	}{
+		{"same text", base, base, 0, 0},
+		{"noise is ignored", base, base + " https://t.co/abc #animals @someone!", 0, 0},
+		{"case and punctuation are ignored", base, "THE QUICK, BROWN FOX... jumps over the lazy dog near the river bank today", 0, 0},
+		{"one changed word", base, "The quick brown fox jumps over the lazy cat near the river bank today", 16, 1},
+		{"different text", base, "Postgres advisory locks serialize imports of the same user across instances", 64, 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, okA := Fingerprint(tt.a)
+			b, okB := Fingerprint(tt.b)
+			if !okA || !okB {
+				t.Fatalf("Fingerprint() ok = %v, %v, want true", okA, okB)
+			}
+			if d := Distance(a, b); d > tt.maxDistance || d < tt.minDistance {
+				t.Errorf("distance = %d, want %d to %d", d, tt.minDistance, tt.maxDistance)
+			}
+		})
+	}
+}
+
+func TestFingerprintShortText(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"one two three four", false},
+		{"one two three four five", true},
+		{"one two three four #five https://x.com", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if _, ok := Fingerprint(tt.text); ok != tt.want {
+			t.Errorf("Fingerprint(%q) ok = %v, want %v", tt.text, ok, tt.want)
+		}
+	}
+}
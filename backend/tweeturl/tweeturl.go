@@ -0,0 +1,52 @@
+// Package tweeturl recognizes and builds links to tweets on x.com and
+// twitter.com.
+package tweeturl
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// hosts are the hosts a tweet link may point to.
+var hosts = map[string]bool{
+	"twitter.com":        true,
+	"www.twitter.com":    true,
+	"mobile.twitter.com": true,
+	"x.com":              true,
+	"www.x.com":          true,
+	"mobile.x.com":       true,
+}
+
+// statusPath matches the path of a tweet link, such as /jack/status/20.
+// Links through /i/ or /i/web/ carry no author.
+var statusPath = regexp.MustCompile(`^/(?:i(?:/web)?|([A-Za-z0-9_]{1,15}))/status(?:es)?/([0-9]+)`)
+
+// IsTweetHost reports whether host is one of X's or Twitter's hosts.
+func IsTweetHost(host string) bool {
+	return hosts[strings.ToLower(host)]
+}
+
+// Parse returns the tweet id a link points to and, when the link names
+// them, its author's username. ok is false for anything but a link to a
+// tweet on x.com or twitter.com.
+func Parse(raw string) (tweetID, username string, ok bool) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || !IsTweetHost(u.Hostname()) {
+		return "", "", false
+	}
+	m := statusPath.FindStringSubmatch(u.Path)
+	if m == nil {
+		return "", "", false
+	}
+	return m[2], m[1], true
+}
+
+// Format returns the x.com link to a tweet, through /i/ when the author is
+// not known.
+func Format(username, tweetID string) string {
+	if username == "" {
+		return "https://x.com/i/status/" + tweetID
+	}
+	return "https://x.com/" + username + "/status/" + tweetID
+}
@@ -0,0 +1,50 @@
+package tweeturl
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		raw          string
+		wantTweetID  string
+		wantUsername string
+		wantOK       bool
+	}{
+		{"https://x.com/jack/status/20", "20", "jack", true},
+		{"https://twitter.com/jack/status/20", "20", "jack", true},
+		{"http://www.twitter.com/jack/statuses/20", "20", "jack", true},
+		{"https://mobile.x.com/jack/status/20/photo/1", "20", "jack", true},
+		{"https://X.COM/Jack_1/status/20?s=20&t=abc", "20", "Jack_1", true},
+		{"  https://x.com/jack/status/20  ", "20", "jack", true},
+		{"https://x.com/i/status/20", "20", "", true},
+		{"https://twitter.com/i/web/status/20", "20", "", true},
+		{"https://x.com/i/bookmarks", "", "", false},
+		{"https://x.com/jack", "", "", false},
+		{"https://x.com/jack/status/", "", "", false},
+		{"https://x.com/averyveryverylongname/status/20", "", "", false},
+		{"https://example.com/jack/status/20", "", "", false},
+		{"https://nitter.net/jack/status/20", "", "", false},
+		{"not a url", "", "", false},
+		{"", "", "", false},
+	}
+	for _, tt := range tests {
+		tweetID, username, ok := Parse(tt.raw)
+		if tweetID != tt.wantTweetID || username != tt.wantUsername || ok != tt.wantOK {
+			t.Errorf("Parse(%q) = %q, %q, %v, want %q, %q, %v",
+				tt.raw, tweetID, username, ok, tt.wantTweetID, tt.wantUsername, tt.wantOK)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		username, tweetID, want string
+	}{
+		{"jack", "20", "https://x.com/jack/status/20"},
+		{"", "20", "https://x.com/i/status/20"},
+	}
+	for _, tt := range tests {
+		if got := Format(tt.username, tt.tweetID); got != tt.want {
+			t.Errorf("Format(%q, %q) = %q, want %q", tt.username, tt.tweetID, got, tt.want)
+		}
+	}
+}
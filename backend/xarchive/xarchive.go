@@ -0,0 +1,307 @@
+// Package xarchive reads the data archive X (Twitter) lets users download.
+// The archive is a zip whose data/ directory holds JavaScript files such as
+// tweets.js, each assigning a JSON array to a window.YTD global:
+//
+//	window.YTD.tweets.part0 = [ { "tweet" : { ... } }, ... ]
+package xarchive
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"twitter-bookmarks-api/models"
+	"twitter-bookmarks-api/tweeturl"
+)
+
+// Sources an archive can provide bookmarks from.
+const (
+	SourceBookmarks = "bookmarks"
+	SourceTweets    = "tweets"
+	SourceLikes     = "likes"
+)
+
+// Limits on what an Archive reads, so that a small zip cannot expand into
+// gigabytes of data. Real archives split tweets.js into parts well below
+// maxFileSize. They are variables so that tests can lower them.
+var (
+	maxFileSize  int64 = 256 << 20
+	maxTotalSize int64 = 512 << 20
+	maxFiles           = 100
+)
+
+var (
+	ErrNotArchiveFile  = errors.New("not a recognized X archive file")
+	ErrFileTooLarge    = fmt.Errorf("file is larger than %d MiB", maxFileSize>>20)
+	ErrArchiveTooLarge = fmt.Errorf("archive data is larger than %d MiB in total", maxTotalSize>>20)
+	ErrTooManyFiles    = fmt.Errorf("archive has more than %d data files", maxFiles)
+
+	tweetsFile    = regexp.MustCompile(`^tweets?(-part\d+)?\.js$`)
+	bookmarksFile = regexp.MustCompile(`^bookmarks?(-part\d+)?\.js$`)
+	likesFile     = regexp.MustCompile(`^likes?(-part\d+)?\.js$`)
+	accountFile   = regexp.MustCompile(`^account\.js$`)
+)
+
+// Archive collects the parsed contents of an X archive.
+type Archive struct {
+	Username    string
+	DisplayName string
+	bookmarks   []models.BookmarkImportItem
+	tweets      []models.BookmarkImportItem
+	likes       []models.BookmarkImportItem
+	files       int
+	size        int64
+}
+
+// AddZip parses every recognized file in an archive zip. Media and other
+// files are skipped without being decompressed.
+func (a *Archive) AddZip(r io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("invalid zip file: %w", err)
+	}
+
+	found := false
+	for _, f := range zr.File {
+		if !IsArchiveFile(f.Name) {
+			continue
+		}
+		if f.UncompressedSize64 > uint64(maxFileSize) {
+			return fmt.Errorf("%s: %w", f.Name, ErrFileTooLarge)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.Name, err)
+		}
+		err = a.AddReader(f.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		found = true
+	}
+
+	if !found {
+		return ErrNotArchiveFile
+	}
+	return nil
+}
+
+// IsArchiveFile reports whether name is a file AddFile understands.
+func IsArchiveFile(name string) bool {
+	base := strings.ToLower(path.Base(strings.ReplaceAll(name, `\`, "/")))
+	return tweetsFile.MatchString(base) || bookmarksFile.MatchString(base) ||
+		likesFile.MatchString(base) || accountFile.MatchString(base)
+}
+
+// AddReader reads and parses a single archive file, such as data/tweets.js,
+// uploaded on its own or read from the zip. It fails without reading further
+// once a limit on the size or number of files is exceeded.
+func (a *Archive) AddReader(name string, r io.Reader) error {
+	if a.files >= maxFiles {
+		return ErrTooManyFiles
+	}
+	limit, tooLarge := maxFileSize, ErrFileTooLarge
+	if remaining := maxTotalSize - a.size; remaining < limit {
+		limit, tooLarge = remaining, ErrArchiveTooLarge
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if int64(len(data)) > limit {
+		return fmt.Errorf("%s: %w", name, tooLarge)
+	}
+	a.files++
+	a.size += int64(len(data))
+	return a.AddFile(name, data)
+}
+
+// AddFile parses a single archive file whose contents were already read.
+func (a *Archive) AddFile(name string, data []byte) error {
+	base := strings.ToLower(path.Base(strings.ReplaceAll(name, `\`, "/")))
+
+	entries, err := parseYTD(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	switch {
+	case accountFile.MatchString(base):
+		for _, entry := range entries {
+			var account struct {
+				Username    string `json:"username"`
+				DisplayName string `json:"accountDisplayName"`
+			}
+			if err := json.Unmarshal(unwrap(entry, "account"), &account); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			a.Username, a.DisplayName = account.Username, account.DisplayName
+		}
+	case tweetsFile.MatchString(base):
+		for _, entry := range entries {
+			var t tweet
+			if err := json.Unmarshal(unwrap(entry, "tweet"), &t); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			a.tweets = append(a.tweets, t.item())
+		}
+	case bookmarksFile.MatchString(base):
+		items, err := parseReferences(entries, "bookmark")
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		a.bookmarks = append(a.bookmarks, items...)
+	case likesFile.MatchString(base):
+		items, err := parseReferences(entries, "like")
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		a.likes = append(a.likes, items...)
+	default:
+		return fmt.Errorf("%s: %w", name, ErrNotArchiveFile)
+	}
+	return nil
+}
+
+// Items returns the import items from the given sources. The user's own
+// tweets get the account's username and display name as author.
+func (a *Archive) Items(sources ...string) []models.BookmarkImportItem {
+	var items []models.BookmarkImportItem
+	for _, source := range sources {
+		switch source {
+		case SourceBookmarks:
+			items = append(items, a.bookmarks...)
+		case SourceLikes:
+			items = append(items, a.likes...)
+		case SourceTweets:
+			for _, item := range a.tweets {
+				item.AuthorUsername = a.Username
+				item.AuthorDisplayName = a.DisplayName
+				item.TweetURL = tweeturl.Format(a.Username, item.TweetID)
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// parseYTD strips the window.YTD assignment from a file and decodes the
+// array it assigns. Plain JSON arrays are accepted too.
+func parseYTD(data []byte) ([]json.RawMessage, error) {
+	data = bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	if bytes.HasPrefix(data, []byte("window.")) {
+		i := bytes.IndexByte(data, '=')
+		if i < 0 {
+			return nil, errors.New("missing assignment")
+		}
+		data = bytes.TrimSpace(data[i+1:])
+	}
+	data = bytes.TrimSuffix(data, []byte(";"))
+
+	var entries []json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid archive data: %w", err)
+	}
+	return entries, nil
+}
+
+// unwrap returns the object under key, as in { "tweet": { ... } }. Older
+// archives store the object directly.
+func unwrap(entry json.RawMessage, key string) json.RawMessage {
+	var wrapper map[string]json.RawMessage
+	if err := json.Unmarshal(entry, &wrapper); err == nil {
+		if inner, ok := wrapper[key]; ok {
+			return inner
+		}
+	}
+	return entry
+}
+
+// parseReferences reads bookmark.js and like.js entries, which only hold a
+// tweet's id, text and link.
+func parseReferences(entries []json.RawMessage, key string) ([]models.BookmarkImportItem, error) {
+	var items []models.BookmarkImportItem
+	for _, entry := range entries {
+		var ref struct {
+			TweetID     string `json:"tweetId"`
+			FullText    string `json:"fullText"`
+			ExpandedURL string `json:"expandedUrl"`
+		}
+		if err := json.Unmarshal(unwrap(entry, key), &ref); err != nil {
+			return nil, err
+		}
+
+		item := models.BookmarkImportItem{TweetID: ref.TweetID, TweetText: html.UnescapeString(ref.FullText)}
+		if tweetID, username, ok := tweeturl.Parse(ref.ExpandedURL); ok && tweetID == ref.TweetID {
+			item.AuthorUsername = username
+		}
+		item.TweetURL = tweeturl.Format(item.AuthorUsername, ref.TweetID)
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+type tweet struct {
+	ID        string `json:"id_str"`
+	FullText  string `json:"full_text"`
+	CreatedAt string `json:"created_at"`
+	Entities  struct {
+		URLs  []urlEntity   `json:"urls"`
+		Media []mediaEntity `json:"media"`
+	} `json:"entities"`
+	ExtendedEntities struct {
+		Media []mediaEntity `json:"media"`
+	} `json:"extended_entities"`
+}
+
+type urlEntity struct {
+	URL         string `json:"url"`
+	ExpandedURL string `json:"expanded_url"`
+}
+
+type mediaEntity struct {
+	URL           string `json:"url"`
+	MediaURLHTTPS string `json:"media_url_https"`
+}
+
+// item converts a tweet, expanding t.co links in its text and collecting
+// its media. created_at uses X's "Wed Oct 10 20:19:24 +0000 2018" format,
+// which the import validation accepts as is.
+func (t tweet) item() models.BookmarkImportItem {
+	// The archive keeps the API's HTML escaping of &, < and >.
+	text := html.UnescapeString(t.FullText)
+	for _, u := range t.Entities.URLs {
+		if u.URL != "" && u.ExpandedURL != "" {
+			text = strings.ReplaceAll(text, u.URL, u.ExpandedURL)
+		}
+	}
+
+	media := t.ExtendedEntities.Media
+	if len(media) == 0 {
+		media = t.Entities.Media
+	}
+	var mediaURLs []string
+	for _, m := range media {
+		if m.URL != "" {
+			text = strings.ReplaceAll(text, m.URL, "")
+		}
+		if m.MediaURLHTTPS != "" {
+			mediaURLs = append(mediaURLs, m.MediaURLHTTPS)
+		}
+	}
+
+	return models.BookmarkImportItem{
+		TweetID:      t.ID,
+		TweetText:    strings.TrimSpace(text),
+		MediaURLs:    mediaURLs,
+		BookmarkedAt: t.CreatedAt,
+	}
+}
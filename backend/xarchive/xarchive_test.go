@@ -0,0 +1,306 @@
+package xarchive
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"twitter-bookmarks-api/models"
+)
+
+type archiveFile struct {
+	name, data string
+}
+
+func makeZip(t *testing.T, files ...archiveFile) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(f.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// setLimits lowers the archive limits for one test.
+func setLimits(t *testing.T, fileSize, totalSize int64, files int) {
+	t.Helper()
+	prevFileSize, prevTotalSize, prevFiles := maxFileSize, maxTotalSize, maxFiles
+	maxFileSize, maxTotalSize, maxFiles = fileSize, totalSize, files
+	t.Cleanup(func() {
+		maxFileSize, maxTotalSize, maxFiles = prevFileSize, prevTotalSize, prevFiles
+	})
+}
+
+func TestParseYTD(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    int
+		wantErr bool
+	}{
+		{"assignment", `window.YTD.tweets.part0 = [ {"tweet": {}}, {"tweet": {}} ]`, 2, false},
+		{"trailing semicolon", `window.YTD.like.part0 = [ {} ];`, 1, false},
+		{"byte order mark", "\ufeffwindow.YTD.account.part0 = [ {} ]", 1, false},
+		{"plain array", `[ {}, {}, {} ]`, 3, false},
+		{"empty array", `window.YTD.bookmark.part0 = []`, 0, false},
+		{"missing assignment", `window.YTD.tweets.part0 [ {} ]`, 0, true},
+		{"not json", `window.YTD.tweets.part0 = [ {`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := parseYTD([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseYTD() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(entries) != tt.want {
+				t.Errorf("parseYTD() returned %d entries, want %d", len(entries), tt.want)
+			}
+		})
+	}
+}
+
+func TestIsArchiveFile(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"data/tweets.js", true},
+		{"data/tweet.js", true},
+		{"data/tweets-part1.js", true},
+		{`data\bookmarks.js`, true},
+		{"data/like.js", true},
+		{"data/account.js", true},
+		{"DATA/TWEETS.JS", true},
+		{"data/direct-messages.js", false},
+		{"data/tweets_media/1.jpg", false},
+		{"data/tweets.json", false},
+	}
+	for _, tt := range tests {
+		if got := IsArchiveFile(tt.name); got != tt.want {
+			t.Errorf("IsArchiveFile(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestArchiveItems(t *testing.T) {
+	account := `window.YTD.account.part0 = [{"account": {"username": "jack", "accountDisplayName": "Jack"}}]`
+	tweets := `window.YTD.tweets.part0 = [{"tweet": {
+		"id_str": "20",
+		"full_text": "just setting up my twttr &amp; more https://t.co/abc https://t.co/img",
+		"created_at": "Tue Mar 21 20:50:14 +0000 2006",
+		"entities": {
+			"urls": [{"url": "https://t.co/abc", "expanded_url": "https://example.com/page"}],
+			"media": [{"url": "https://t.co/img", "media_url_https": "https://pbs.twimg.com/media/small.jpg"}]
+		},
+		"extended_entities": {
+			"media": [{"url": "https://t.co/img", "media_url_https": "https://pbs.twimg.com/media/a.jpg"}]
+		}
+	}}]`
+	bookmarks := `window.YTD.bookmark.part0 = [
+		{"bookmark": {"tweetId": "30", "fullText": "a &lt;b&gt;", "expandedUrl": "https://twitter.com/alice/status/30"}},
+		{"bookmark": {"tweetId": "31", "fullText": "no author", "expandedUrl": "https://twitter.com/i/web/status/31"}}
+	]`
+	likes := `window.YTD.like.part0 = [{"like": {"tweetId": "40", "fullText": "liked", "expandedUrl": "https://x.com/bob/status/41"}}]`
+
+	tests := []struct {
+		name    string
+		sources []string
+		want    []models.BookmarkImportItem
+	}{
+		{
+			name:    "tweets",
+			sources: []string{SourceTweets},
+			want: []models.BookmarkImportItem{{
+				TweetID:           "20",
+				TweetText:         "just setting up my twttr & more https://example.com/page",
+				AuthorUsername:    "jack",
+				AuthorDisplayName: "Jack",
+				TweetURL:          "https://x.com/jack/status/20",
+				MediaURLs:         []string{"https://pbs.twimg.com/media/a.jpg"},
+				BookmarkedAt:      "Tue Mar 21 20:50:14 +0000 2006",
+			}},
+		},
+		{
+			name:    "bookmarks",
+			sources: []string{SourceBookmarks},
+			want: []models.BookmarkImportItem{
+				{TweetID: "30", TweetText: "a <b>", AuthorUsername: "alice", TweetURL: "https://x.com/alice/status/30"},
+				{TweetID: "31", TweetText: "no author", TweetURL: "https://x.com/i/status/31"},
+			},
+		},
+		{
+			// The link points to another tweet, so its author is not used.
+			name:    "likes",
+			sources: []string{SourceLikes},
+			want: []models.BookmarkImportItem{
+				{TweetID: "40", TweetText: "liked", TweetURL: "https://x.com/i/status/40"},
+			},
+		},
+		{
+			name:    "no sources",
+			sources: nil,
+			want:    nil,
+		},
+	}
+
+	archive := &Archive{}
+	for _, f := range []archiveFile{
+		{"data/account.js", account},
+		{"data/tweets.js", tweets},
+		{"data/bookmark.js", bookmarks},
+		{"data/like.js", likes},
+	} {
+		if err := archive.AddFile(f.name, []byte(f.data)); err != nil {
+			t.Fatalf("AddFile(%s) error = %v", f.name, err)
+		}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := archive.Items(tt.sources...)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Items(%v) = %+v, want %+v", tt.sources, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		data    string
+		wantErr error
+	}{
+		{"unknown file", "data/direct-messages.js", `[]`, ErrNotArchiveFile},
+		{"invalid data", "data/tweets.js", `window.YTD.tweets.part0 = {`, nil},
+		{"invalid entry", "data/bookmark.js", `[{"bookmark": {"tweetId": 30}}]`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&Archive{}).AddFile(tt.file, []byte(tt.data))
+			if err == nil {
+				t.Fatal("AddFile() error = nil, want an error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("AddFile() error = %v, want %v", err, tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.file) {
+				t.Errorf("AddFile() error = %v, want it to name %s", err, tt.file)
+			}
+		})
+	}
+}
+
+func TestAddZipLimits(t *testing.T) {
+	bookmark := `[{"bookmark": {"tweetId": "1", "fullText": "x"}}]`
+	padded := bookmark + strings.Repeat(" ", 100)
+
+	tests := []struct {
+		name      string
+		fileSize  int64
+		totalSize int64
+		files     int
+		zip       []archiveFile
+		wantItems int
+		wantErr   error
+	}{
+		{
+			name:     "within limits",
+			fileSize: 1 << 10, totalSize: 1 << 10, files: 2,
+			zip: []archiveFile{
+				{"data/bookmark.js", bookmark},
+				{"data/bookmark-part1.js", bookmark},
+				{"data/tweets_media/1.jpg", strings.Repeat("x", 4<<10)},
+			},
+			wantItems: 2,
+		},
+		{
+			name:     "file too large",
+			fileSize: 64, totalSize: 1 << 10, files: 10,
+			zip:     []archiveFile{{"data/bookmark.js", padded}},
+			wantErr: ErrFileTooLarge,
+		},
+		{
+			name:     "total too large",
+			fileSize: 1 << 10, totalSize: int64(len(padded)) + 10, files: 10,
+			zip: []archiveFile{
+				{"data/bookmark.js", padded},
+				{"data/bookmark-part1.js", padded},
+			},
+			wantErr: ErrArchiveTooLarge,
+		},
+		{
+			name:     "too many files",
+			fileSize: 1 << 10, totalSize: 1 << 10, files: 1,
+			zip: []archiveFile{
+				{"data/bookmark.js", bookmark},
+				{"data/bookmark-part1.js", bookmark},
+			},
+			wantErr: ErrTooManyFiles,
+		},
+		{
+			name:     "no archive files",
+			fileSize: 1 << 10, totalSize: 1 << 10, files: 10,
+			zip:     []archiveFile{{"README.txt", "hello"}},
+			wantErr: ErrNotArchiveFile,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setLimits(t, tt.fileSize, tt.totalSize, tt.files)
+			data := makeZip(t, tt.zip...)
+
+			archive := &Archive{}
+			err := archive.AddZip(bytes.NewReader(data), int64(len(data)))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AddZip() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := len(archive.Items(SourceBookmarks)); err == nil && got != tt.wantItems {
+				t.Errorf("AddZip() read %d bookmarks, want %d", got, tt.wantItems)
+			}
+		})
+	}
+}
+
+// TestAddReaderDoesNotTruncate checks that a file longer than the limit is
+// rejected rather than cut off and parsed, whatever its zip header claims.
+func TestAddReaderDoesNotTruncate(t *testing.T) {
+	setLimits(t, 16, 1<<10, 10)
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr error
+	}{
+		{"at the limit", `[{"bookmark":1}]`, nil},
+		{"one byte over", `[{"bookmark":10}]`, ErrFileTooLarge},
+		{"far over", `[` + strings.Repeat(`{},`, 100) + `{}]`, ErrFileTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&Archive{}).AddReader("data/bookmark.js", strings.NewReader(tt.data))
+			if tt.wantErr == nil {
+				if errors.Is(err, ErrFileTooLarge) {
+					t.Fatalf("AddReader() error = %v, want no size error", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AddReader() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
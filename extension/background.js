@@ -8,6 +8,11 @@ chrome.runtime.onMessage.addListener((message, sender, sendResponse) => {
       .then(result => sendResponse(result))
       .catch(error => sendResponse({ success: false, error: error.message }));
     return true; // Keep channel open for async response
+  } else if (message.type === 'GET_SYNC_STATE') {
+    getSyncState()
+      .then(result => sendResponse(result))
+      .catch(error => sendResponse({ success: false, error: error.message }));
+    return true; // Keep channel open for async response
   } else if (message.type === 'TEST_CONNECTION') {
     testConnection(message.apiUrl, message.token)
       .then(result => sendResponse(result))
@@ -28,22 +33,42 @@ async function handleBookmarkSync(bookmarks) {
     
     console.log(`Knowlex: Syncing ${bookmarks.length} bookmarks to ${config.apiUrl}`);
     
-    // Send bookmarks to API
-    const response = await fetch(`${config.apiUrl}/api/bookmarks/import`, {
+    // Stream bookmarks to API as newline-delimited JSON so large syncs are
+    // saved in batches instead of one huge request. source=extension makes
+    // this sync the starting point for the next one. The Idempotency-Key is
+    // the same for every attempt of this sync, so a retry after a dropped
+    // connection gets the stored result instead of importing twice.
+    const idempotencyKey = crypto.randomUUID();
+    const body = bookmarks.map((bookmark) => JSON.stringify(bookmark)).join('\n');
+    const send = () => fetch(`${config.apiUrl}/api/bookmarks/import/stream?source=extension`, {
       method: 'POST',
       headers: {
         'Authorization': `Bearer ${config.authToken}`,
-        'Content-Type': 'application/json'
+        'Content-Type': 'application/x-ndjson',
+        'Idempotency-Key': idempotencyKey
       },
-      body: JSON.stringify({ bookmarks })
+      body
     });
     
+    let response;
+    try {
+      response = await send();
+    } catch (networkError) {
+      console.warn('Knowlex: Sync request failed, retrying once', networkError);
+      response = await send();
+    }
+    
     if (!response.ok) {
       const errorData = await response.json().catch(() => ({ error: 'Unknown error' }));
       throw new Error(errorData.error || `HTTP ${response.status}: ${response.statusText}`);
     }
     
-    const data = await response.json();
+    // The last progress line holds the final counts
+    const lines = (await response.text()).trim().split('\n');
+    const data = JSON.parse(lines[lines.length - 1]);
+    if (data.error) {
+      throw new Error(data.error);
+    }
     
     console.log('Knowlex: Sync successful', data);
     
@@ -142,6 +167,34 @@ chrome.tabs.onUpdated.addListener((tabId, changeInfo, tab) => {
   }
 });
 
+// Fetch the tweets the last sync started with, so extraction can stop
+// scrolling once it reaches them
+async function getSyncState() {
+  const config = await chrome.storage.local.get(['apiUrl', 'authToken']);
+
+  if (!config.apiUrl || !config.authToken) {
+    throw new Error('API URL or auth token not configured');
+  }
+
+  const response = await fetch(`${config.apiUrl}/api/bookmarks/sync-state`, {
+    method: 'GET',
+    headers: {
+      'Authorization': `Bearer ${config.authToken}`
+    }
+  });
+
+  if (!response.ok) {
+    throw new Error(`HTTP ${response.status}: ${response.statusText}`);
+  }
+
+  const state = await response.json();
+  return {
+    success: true,
+    newestTweetIds: state.newest_tweet_ids || [],
+    lastSyncedAt: state.last_synced_at
+  };
+}
+
 // Helper to test API connection
 async function testConnection(apiUrl, authToken) {
   try {
@@ -52,8 +52,15 @@ async function startExtraction() {
   showOverlay('Initializing...', 0);
   
   try {
-    // Auto-scroll to load all bookmarks
-    await autoScroll();
+    // Bookmarks are listed newest first, so once a tweet from the start of
+    // the last sync shows up, everything below it is already synced
+    const syncedTweetIds = await getSyncedTweetIds();
+    if (syncedTweetIds.size > 0) {
+      console.log(`Knowlex: Will stop at ${syncedTweetIds.size} already synced tweets`);
+    }
+
+    // Auto-scroll to load new bookmarks
+    await autoScroll(syncedTweetIds);
     
     // Collect bookmarks one final time in case new ones appeared after scrolling stopped
     collectVisibleBookmarks();
@@ -98,7 +105,31 @@ async function startExtraction() {
   }
 }
 
-async function autoScroll() {
+// Ask the server which tweets the last sync started with. Without them the
+// whole page is scrolled.
+function getSyncedTweetIds() {
+  return new Promise((resolve) => {
+    chrome.runtime.sendMessage({ type: 'GET_SYNC_STATE' }, (response) => {
+      if (chrome.runtime.lastError || !response || !response.success) {
+        console.warn('Knowlex: Could not load sync state, scanning all bookmarks');
+        resolve(new Set());
+        return;
+      }
+      resolve(new Set(response.newestTweetIds));
+    });
+  });
+}
+
+function reachedSyncedTweet(syncedTweetIds) {
+  for (const tweetId of extractedTweetIds) {
+    if (syncedTweetIds.has(tweetId)) {
+      return true;
+    }
+  }
+  return false;
+}
+
+async function autoScroll(syncedTweetIds = new Set()) {
   const scrollingElement = document.scrollingElement || document.documentElement;
   let scrollCount = 0;
   let stableAttempts = 0;
@@ -114,6 +145,10 @@ async function autoScroll() {
     await new Promise((resolve) => setTimeout(resolve, 1200));
 
     collectVisibleBookmarks();
+    if (reachedSyncedTweet(syncedTweetIds)) {
+      console.log('Knowlex: Reached bookmarks synced last time, stopping');
+      break;
+    }
     const newTweetCount = document.querySelectorAll('article[data-testid="tweet"]').length;
 
     if (newTweetCount <= currentTweetCount) {